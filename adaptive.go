@@ -0,0 +1,119 @@
+package porter
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultAdaptiveMaxLimit       = 100
+	defaultAdaptiveWindow         = 1 * time.Second
+	defaultAdaptiveErrorRate      = 0.1
+	defaultAdaptiveDecreaseFactor = 0.5
+)
+
+// AdaptiveConcurrency configures the jobs limit that is adjusted at runtime by the AIMD algorithm:
+// the limit grows by one every window while the jobs are healthy and the limit is reached,
+// and is multiplied by DecreaseFactor when the latency or the error rate exceeds the thresholds
+type AdaptiveConcurrency struct {
+	// The lowest limit, 1 by default
+	MinLimit int
+	// The highest limit, 100 by default
+	MaxLimit int
+	// Interval between adjustments of the limit, 1s by default
+	Window time.Duration
+	// Average job latency above which the limit is decreased, the latency is ignored if zero
+	LatencyThreshold time.Duration
+	// Share of failed jobs above which the limit is decreased, 0.1 by default.
+	// Any error except the errors of ClassIdle is a failure
+	ErrorRateThreshold float64
+	// Multiplier of the limit on decrease, 0.5 by default
+	DecreaseFactor float64
+}
+
+// WithAdaptiveConcurrency adjusts the jobs limit depending on the job latency and error rate,
+// the limit set by WithJobsLimit is the initial one
+func WithAdaptiveConcurrency(config AdaptiveConcurrency) Opt {
+	return func(w *worker) {
+		if config.MinLimit <= 0 {
+			config.MinLimit = 1
+		}
+		if config.MaxLimit <= 0 {
+			config.MaxLimit = defaultAdaptiveMaxLimit
+		}
+		if config.MaxLimit < config.MinLimit {
+			config.MaxLimit = config.MinLimit
+		}
+		if config.Window <= 0 {
+			config.Window = defaultAdaptiveWindow
+		}
+		if config.ErrorRateThreshold <= 0 {
+			config.ErrorRateThreshold = defaultAdaptiveErrorRate
+		}
+		if config.DecreaseFactor <= 0 || config.DecreaseFactor >= 1 {
+			config.DecreaseFactor = defaultAdaptiveDecreaseFactor
+		}
+
+		w.config.adaptive = &config
+	}
+}
+
+// adaptiveLimit collects the job results of the current window
+type adaptiveLimit struct {
+	config AdaptiveConcurrency
+
+	mu       sync.Mutex
+	jobs     int
+	failures int
+	latency  time.Duration
+}
+
+func newAdaptiveLimit(config AdaptiveConcurrency) *adaptiveLimit {
+	return &adaptiveLimit{config: config}
+}
+
+func (a *adaptiveLimit) observe(latency time.Duration, failed bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.jobs++
+	a.latency += latency
+	if failed {
+		a.failures++
+	}
+}
+
+// next returns the limit for the next window, saturated means that the limit was reached in the current one
+func (a *adaptiveLimit) next(limit int, saturated bool) int {
+	a.mu.Lock()
+	jobs, failures, latency := a.jobs, a.failures, a.latency
+	a.jobs, a.failures, a.latency = 0, 0, 0
+	a.mu.Unlock()
+
+	if jobs > 0 {
+		errorRate := float64(failures) / float64(jobs)
+		avgLatency := latency / time.Duration(jobs)
+
+		if errorRate > a.config.ErrorRateThreshold ||
+			(a.config.LatencyThreshold > 0 && avgLatency > a.config.LatencyThreshold) {
+			return a.clamp(int(float64(limit) * a.config.DecreaseFactor))
+		}
+	}
+
+	if saturated {
+		return a.clamp(limit + 1)
+	}
+
+	return a.clamp(limit)
+}
+
+func (a *adaptiveLimit) clamp(limit int) int {
+	if limit < a.config.MinLimit {
+		return a.config.MinLimit
+	}
+	if limit > a.config.MaxLimit {
+		return a.config.MaxLimit
+	}
+
+	return limit
+}
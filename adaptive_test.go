@@ -0,0 +1,116 @@
+package porter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdaptiveLimit_Next(t *testing.T) {
+	config := AdaptiveConcurrency{
+		MinLimit:           2,
+		MaxLimit:           10,
+		LatencyThreshold:   100 * time.Millisecond,
+		ErrorRateThreshold: 0.5,
+		DecreaseFactor:     0.5,
+	}
+
+	t.Run("Increase", func(t *testing.T) {
+		a := newAdaptiveLimit(config)
+		a.observe(10*time.Millisecond, false)
+
+		assert.Equal(t, 5, a.next(4, true))
+		assert.Equal(t, 10, a.next(10, true))
+	})
+
+	t.Run("NotSaturated", func(t *testing.T) {
+		a := newAdaptiveLimit(config)
+		a.observe(10*time.Millisecond, false)
+
+		assert.Equal(t, 4, a.next(4, false))
+	})
+
+	t.Run("ErrorRate", func(t *testing.T) {
+		a := newAdaptiveLimit(config)
+		a.observe(10*time.Millisecond, true)
+		a.observe(10*time.Millisecond, true)
+		a.observe(10*time.Millisecond, false)
+
+		assert.Equal(t, 4, a.next(8, true))
+		assert.Equal(t, 5, a.next(4, true), "the window must be reset")
+	})
+
+	t.Run("Latency", func(t *testing.T) {
+		a := newAdaptiveLimit(config)
+		a.observe(300*time.Millisecond, false)
+		a.observe(10*time.Millisecond, false)
+
+		assert.Equal(t, 2, a.next(3, true))
+	})
+}
+
+func TestWorker_AdaptiveConcurrency(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	w := NewWorker(
+		func(state State) error {
+			select {
+			case <-release:
+			case <-state.Context().Done():
+			}
+
+			return nil
+		},
+		WithJobsLimit(1),
+		WithAdaptiveConcurrency(AdaptiveConcurrency{
+			MaxLimit: 3,
+			Window:   10 * time.Millisecond,
+		}),
+	)
+
+	assert.NoError(t, w.Run())
+
+	// every job is in progress, so the limit is reached and grows up to the max
+	time.Sleep(100 * time.Millisecond)
+
+	p := w.(*worker).pool
+	p.mu.Lock()
+	limit, inflight := p.limit, p.inflight
+	p.mu.Unlock()
+
+	assert.Equal(t, 3, limit)
+	assert.Equal(t, 3, inflight)
+	assert.NoError(t, w.Shutdown(context.Background()))
+}
+
+func TestWorker_AdaptiveSetJobsLimit(t *testing.T) {
+	w := NewWorker(
+		func(state State) error {
+			<-state.Context().Done()
+			return nil
+		},
+		WithAdaptiveConcurrency(AdaptiveConcurrency{
+			MinLimit: 2,
+			MaxLimit: 3,
+			Window:   time.Minute,
+		}),
+	)
+
+	assert.NoError(t, w.Run())
+
+	// the limit set by hand is kept within the bounds of the adaptive concurrency
+	p := w.(*worker).pool
+	for limit, expected := range map[int]int{1000: 3, 1: 2} {
+		assert.NoError(t, w.SetJobsLimit(limit))
+
+		p.mu.Lock()
+		assert.Equal(t, expected, p.limit)
+		assert.LessOrEqual(t, len(p.slots), 3)
+		p.mu.Unlock()
+	}
+
+	assert.NoError(t, w.Shutdown(context.Background()))
+}
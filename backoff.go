@@ -0,0 +1,114 @@
+package porter
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff calculates the delay after a failed job
+type Backoff interface {
+	// Timeout returns the delay after the given number of consecutive failures,
+	// prev is the delay returned for the previous failure or zero for the first one
+	Timeout(failures int, prev time.Duration) time.Duration
+}
+
+// BackoffFunc is an adapter to use an ordinary function as a Backoff
+type BackoffFunc func(failures int, prev time.Duration) time.Duration
+
+func (f BackoffFunc) Timeout(failures int, prev time.Duration) time.Duration {
+	return f(failures, prev)
+}
+
+// WithErrorBackoff replaces the fixed error timeout with a delay that depends on consecutive failures
+func WithErrorBackoff(backoff Backoff) Opt {
+	return func(w *worker) {
+		w.config.errorBackoff = backoff
+	}
+}
+
+// ConstantBackoff always returns the same delay
+func ConstantBackoff(timeout time.Duration) Backoff {
+	return BackoffFunc(func(_ int, _ time.Duration) time.Duration {
+		return timeout
+	})
+}
+
+// LinearBackoff increases the delay by step after every failure, but not above the limit
+func LinearBackoff(initial, step, limit time.Duration) Backoff {
+	return BackoffFunc(func(failures int, _ time.Duration) time.Duration {
+		steps := time.Duration(failures - 1)
+		if step > 0 && steps > (limit-initial)/step {
+			return limit
+		}
+
+		timeout := initial + steps*step
+		if timeout > limit {
+			return limit
+		}
+
+		return timeout
+	})
+}
+
+// ExponentialBackoff doubles the delay after every failure, but not above the limit
+func ExponentialBackoff(initial, limit time.Duration) Backoff {
+	return BackoffFunc(func(failures int, _ time.Duration) time.Duration {
+		timeout := initial
+		for i := 1; i < failures; i++ {
+			if timeout >= limit/2 {
+				return limit
+			}
+			timeout *= 2
+		}
+
+		if timeout > limit {
+			return limit
+		}
+
+		return timeout
+	})
+}
+
+// DecorrelatedJitterBackoff picks a random delay between base and three times the previous delay, but not above the limit
+func DecorrelatedJitterBackoff(base, limit time.Duration) Backoff {
+	return BackoffFunc(func(_ int, prev time.Duration) time.Duration {
+		if prev < base {
+			prev = base
+		}
+
+		upper := prev * 3
+		if upper > limit || upper < prev {
+			upper = limit
+		}
+
+		if upper <= base {
+			return upper
+		}
+
+		// nolint gosec
+		return base + time.Duration(rand.Int63n(int64(upper-base)))
+	})
+}
+
+// failureStreak tracks consecutive failures of an executor
+type failureStreak struct {
+	failures int
+	timeout  time.Duration
+}
+
+func (s *failureStreak) next(c workerConfig) time.Duration {
+	s.failures++
+
+	if c.errorBackoff == nil {
+		return c.errorTimeout
+	}
+
+	s.timeout = c.errorBackoff.Timeout(s.failures, s.timeout)
+
+	return s.timeout
+}
+
+func (s *failureStreak) reset() {
+	s.failures = 0
+	s.timeout = 0
+}
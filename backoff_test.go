@@ -0,0 +1,58 @@
+package porter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConstantBackoff(t *testing.T) {
+	b := ConstantBackoff(time.Second)
+
+	assert.Equal(t, time.Second, b.Timeout(1, 0))
+	assert.Equal(t, time.Second, b.Timeout(10, time.Second))
+}
+
+func TestLinearBackoff(t *testing.T) {
+	b := LinearBackoff(time.Second, 2*time.Second, 6*time.Second)
+
+	assert.Equal(t, 1*time.Second, b.Timeout(1, 0))
+	assert.Equal(t, 3*time.Second, b.Timeout(2, 0))
+	assert.Equal(t, 5*time.Second, b.Timeout(3, 0))
+	assert.Equal(t, 6*time.Second, b.Timeout(4, 0))
+	assert.Equal(t, 6*time.Second, b.Timeout(1<<62, 0))
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	b := ExponentialBackoff(time.Second, 10*time.Second)
+
+	assert.Equal(t, 1*time.Second, b.Timeout(1, 0))
+	assert.Equal(t, 2*time.Second, b.Timeout(2, 0))
+	assert.Equal(t, 4*time.Second, b.Timeout(3, 0))
+	assert.Equal(t, 8*time.Second, b.Timeout(4, 0))
+	assert.Equal(t, 10*time.Second, b.Timeout(5, 0))
+	assert.Equal(t, 10*time.Second, b.Timeout(1000, 0))
+}
+
+func TestDecorrelatedJitterBackoff(t *testing.T) {
+	const (
+		base  = 100 * time.Millisecond
+		limit = time.Second
+	)
+
+	b := DecorrelatedJitterBackoff(base, limit)
+
+	prev := time.Duration(0)
+	for i := 1; i <= 100; i++ {
+		timeout := b.Timeout(i, prev)
+
+		assert.GreaterOrEqual(t, int64(timeout), int64(base))
+		assert.LessOrEqual(t, int64(timeout), int64(limit))
+		if prev > 0 {
+			assert.LessOrEqual(t, int64(timeout), int64(3*prev))
+		}
+
+		prev = timeout
+	}
+}
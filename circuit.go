@@ -0,0 +1,275 @@
+package porter
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+const (
+	defaultCircuitFailureRatio = 0.5
+	defaultCircuitMinRequests  = 10
+	defaultCircuitWindow       = 10 * time.Second
+	defaultCircuitOpenTimeout  = 30 * time.Second
+	circuitBuckets             = 10
+)
+
+// CircuitState is the state of the circuit breaker
+type CircuitState int
+
+const (
+	// CircuitClosed means the jobs are performed and their results are counted
+	CircuitClosed CircuitState = iota
+	// CircuitOpen means the jobs are not performed and return ErrCircuitOpen
+	CircuitOpen
+	// CircuitHalfOpen means a limited number of probe jobs is performed to check whether the dependency recovered
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitChange describes a change of the circuit breaker state
+type CircuitChange struct {
+	// Name of the circuit breaker
+	Name string
+	From CircuitState
+	To   CircuitState
+}
+
+// CircuitBreaker configures CircuitBreakerMiddleware
+type CircuitBreaker struct {
+	// Name of the circuit breaker reported by the events
+	Name string
+	// Share of the failed jobs within the window that opens the circuit, 0.5 by default
+	FailureRatio float64
+	// The lowest number of jobs within the window to open the circuit, 10 by default
+	MinRequests int
+	// Rolling window of the counted jobs, 10s by default
+	Window time.Duration
+	// Time after which the open circuit lets the probe jobs through, 30s by default
+	OpenTimeout time.Duration
+	// Number of the successful probe jobs that closes the circuit, 1 by default
+	HalfOpenRequests int
+	// IsFailure reports whether the job error is a failure of the dependency,
+	// by default any error except ErrIdleJob and ErrCircuitOpen is a failure
+	IsFailure func(error) bool
+}
+
+// CircuitBreakerMiddleware stops performing the jobs while the share of failures is too high,
+// the jobs return ErrCircuitOpen instead, which is classified as ClassIdle, so it is delayed by the idle timeout
+// and is not reported as a job error.
+// The changes of the state are emitted by the worker that performed the job.
+// The middleware can be shared by several workers that use the same dependency
+func CircuitBreakerMiddleware(config CircuitBreaker) MiddlewareFunc {
+	cb := newCircuitBreaker(config)
+
+	return func(next JobFunc) JobFunc {
+		return func(state State) error {
+			generation, probe, err := cb.allow(time.Now(), state)
+			if err != nil {
+				return err
+			}
+
+			// the panic of the job is a failure too
+			failure := true
+			defer func() {
+				cb.record(time.Now(), state, generation, probe, failure)
+			}()
+
+			err = next(state)
+			failure = cb.config.IsFailure(err)
+
+			return err
+		}
+	}
+}
+
+type circuitBucket struct {
+	// Number of the window interval the bucket belongs to
+	epoch    int64
+	requests int
+	failures int
+}
+
+type circuitBreaker struct {
+	config     CircuitBreaker
+	bucketSize time.Duration
+
+	mu    sync.Mutex
+	state CircuitState
+	// Incremented on every change of the state to ignore the results of the jobs started before
+	generation int
+	openedAt   time.Time
+	// Probe jobs in progress and succeeded in the half-open state
+	probes    int
+	successes int
+	buckets   [circuitBuckets]circuitBucket
+}
+
+func newCircuitBreaker(config CircuitBreaker) *circuitBreaker {
+	if config.FailureRatio <= 0 || config.FailureRatio > 1 {
+		config.FailureRatio = defaultCircuitFailureRatio
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = defaultCircuitMinRequests
+	}
+	if config.Window <= 0 {
+		config.Window = defaultCircuitWindow
+	}
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = defaultCircuitOpenTimeout
+	}
+	if config.HalfOpenRequests <= 0 {
+		config.HalfOpenRequests = 1
+	}
+	if config.IsFailure == nil {
+		config.IsFailure = func(err error) bool {
+			return err != nil && !errors.Is(err, ErrIdleJob) && !errors.Is(err, ErrCircuitOpen)
+		}
+	}
+
+	bucketSize := config.Window / circuitBuckets
+	if bucketSize <= 0 {
+		bucketSize = 1
+	}
+
+	return &circuitBreaker{
+		config:     config,
+		bucketSize: bucketSize,
+	}
+}
+
+// allow returns ErrCircuitOpen if the job must not be performed,
+// otherwise it returns the generation of the state and whether the job is a probe
+func (cb *circuitBreaker) allow(now time.Time, state State) (int, bool, error) {
+	cb.mu.Lock()
+
+	var change *CircuitChange
+	if cb.state == CircuitOpen && now.Sub(cb.openedAt) >= cb.config.OpenTimeout {
+		change = cb.setState(CircuitHalfOpen)
+	}
+
+	generation, probe, err := cb.admit()
+	cb.mu.Unlock()
+
+	notifyCircuitChange(state, change)
+
+	return generation, probe, err
+}
+
+// admit must be called under mu
+func (cb *circuitBreaker) admit() (int, bool, error) {
+	switch cb.state {
+	case CircuitOpen:
+		return 0, false, ErrCircuitOpen
+	case CircuitHalfOpen:
+		if cb.probes+cb.successes >= cb.config.HalfOpenRequests {
+			return 0, false, ErrCircuitOpen
+		}
+
+		cb.probes++
+
+		return cb.generation, true, nil
+	default:
+		return cb.generation, false, nil
+	}
+}
+
+// record counts the result of the job, the results of the jobs started in another state are ignored
+func (cb *circuitBreaker) record(now time.Time, state State, generation int, probe, failure bool) {
+	cb.mu.Lock()
+
+	if generation != cb.generation {
+		cb.mu.Unlock()
+		return
+	}
+
+	var change *CircuitChange
+	switch {
+	case probe && failure:
+		change = cb.open(now)
+	case probe:
+		cb.probes--
+		cb.successes++
+		if cb.successes >= cb.config.HalfOpenRequests {
+			change = cb.setState(CircuitClosed)
+		}
+	default:
+		requests, failures := cb.count(now, failure)
+		if requests >= cb.config.MinRequests && float64(failures) >= cb.config.FailureRatio*float64(requests) {
+			change = cb.open(now)
+		}
+	}
+
+	cb.mu.Unlock()
+
+	notifyCircuitChange(state, change)
+}
+
+// count adds the result to the current bucket and returns the totals within the window, it must be called under mu
+func (cb *circuitBreaker) count(now time.Time, failure bool) (int, int) {
+	epoch := now.UnixNano() / int64(cb.bucketSize)
+
+	bucket := &cb.buckets[epoch%circuitBuckets]
+	if bucket.epoch != epoch {
+		*bucket = circuitBucket{epoch: epoch}
+	}
+
+	bucket.requests++
+	if failure {
+		bucket.failures++
+	}
+
+	requests, failures := 0, 0
+	for _, b := range cb.buckets {
+		if epoch-b.epoch < circuitBuckets {
+			requests += b.requests
+			failures += b.failures
+		}
+	}
+
+	return requests, failures
+}
+
+// open must be called under mu
+func (cb *circuitBreaker) open(now time.Time) *CircuitChange {
+	cb.openedAt = now
+	return cb.setState(CircuitOpen)
+}
+
+// setState resets the counters and returns the change, it must be called under mu
+func (cb *circuitBreaker) setState(to CircuitState) *CircuitChange {
+	change := &CircuitChange{Name: cb.config.Name, From: cb.state, To: to}
+
+	cb.state = to
+	cb.generation++
+	cb.probes = 0
+	cb.successes = 0
+	cb.buckets = [circuitBuckets]circuitBucket{}
+
+	return change
+}
+
+// notifyCircuitChange emits the change through the worker performing the job
+func notifyCircuitChange(state State, change *CircuitChange) {
+	if change == nil {
+		return
+	}
+
+	if sl := slotFromContext(state.Context()); sl != nil {
+		sl.pool.events.OnCircuitChange(*change)
+	}
+}
@@ -0,0 +1,143 @@
+package porter
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	s := &state{}
+	now := time.Now()
+
+	newBreaker := func() *circuitBreaker {
+		return newCircuitBreaker(CircuitBreaker{
+			FailureRatio: 0.5,
+			MinRequests:  4,
+			Window:       10 * time.Second,
+			OpenTimeout:  5 * time.Second,
+		})
+	}
+
+	call := func(cb *circuitBreaker, at time.Time, failure bool) error {
+		generation, probe, err := cb.allow(at, s)
+		if err != nil {
+			return err
+		}
+
+		cb.record(at, s, generation, probe, failure)
+
+		return nil
+	}
+
+	t.Run("Open", func(t *testing.T) {
+		cb := newBreaker()
+
+		assert.NoError(t, call(cb, now, false))
+		assert.NoError(t, call(cb, now, false))
+		assert.NoError(t, call(cb, now, true))
+		assert.Equal(t, CircuitClosed, cb.state)
+
+		assert.NoError(t, call(cb, now, true))
+		assert.Equal(t, CircuitOpen, cb.state)
+		assert.Equal(t, ErrCircuitOpen, call(cb, now.Add(time.Second), false))
+	})
+
+	t.Run("Window", func(t *testing.T) {
+		cb := newBreaker()
+
+		assert.NoError(t, call(cb, now, true))
+		assert.NoError(t, call(cb, now, true))
+
+		// the failures are out of the window
+		later := now.Add(11 * time.Second)
+		assert.NoError(t, call(cb, later, false))
+		assert.NoError(t, call(cb, later, false))
+		assert.NoError(t, call(cb, later, true))
+		assert.NoError(t, call(cb, later, false))
+		assert.Equal(t, CircuitClosed, cb.state)
+	})
+
+	t.Run("HalfOpen", func(t *testing.T) {
+		cb := newBreaker()
+		for i := 0; i < 4; i++ {
+			assert.NoError(t, call(cb, now, true))
+		}
+		assert.Equal(t, CircuitOpen, cb.state)
+
+		// the failed probe opens the circuit again
+		later := now.Add(5 * time.Second)
+		assert.NoError(t, call(cb, later, true))
+		assert.Equal(t, CircuitOpen, cb.state)
+
+		// only one probe at a time
+		later = later.Add(5 * time.Second)
+		generation, probe, err := cb.allow(later, s)
+		assert.NoError(t, err)
+		assert.True(t, probe)
+		assert.Equal(t, CircuitHalfOpen, cb.state)
+		assert.Equal(t, ErrCircuitOpen, call(cb, later, false))
+
+		cb.record(later, s, generation, probe, false)
+		assert.Equal(t, CircuitClosed, cb.state)
+	})
+
+	t.Run("StaleResult", func(t *testing.T) {
+		cb := newBreaker()
+
+		generation, probe, err := cb.allow(now, s)
+		assert.NoError(t, err)
+
+		for i := 0; i < 4; i++ {
+			assert.NoError(t, call(cb, now, true))
+		}
+
+		// the job started before the circuit was opened doesn't affect the state
+		cb.record(now, s, generation, probe, false)
+		assert.Equal(t, CircuitOpen, cb.state)
+	})
+}
+
+func TestCircuitBreakerMiddleware(t *testing.T) {
+	errTest := errors.New("test error")
+	changes := make(chan CircuitChange, 10)
+	var jobs, jobErrors int64
+
+	w := NewWorker(
+		func(state State) error {
+			atomic.AddInt64(&jobs, 1)
+			return errTest
+		},
+		WithMiddleware(CircuitBreakerMiddleware(CircuitBreaker{
+			Name:        "db",
+			MinRequests: 2,
+			OpenTimeout: time.Minute,
+		})),
+		WithIdleTimeout(10*time.Millisecond),
+		WithSubscriber(func(s Subscriber) {
+			s.ListenCircuitChange(func(change CircuitChange) {
+				changes <- change
+			})
+			s.ListenJobError(func(_ ErrorClass, _ error) {
+				atomic.AddInt64(&jobErrors, 1)
+			})
+		}),
+	)
+
+	assert.NoError(t, w.Run())
+	assert.Equal(t, CircuitChange{Name: "db", From: CircuitClosed, To: CircuitOpen}, <-changes)
+
+	// the short-circuited jobs are idle, so they are delayed by the idle timeout and are not reported as errors
+	time.Sleep(100 * time.Millisecond)
+	stats := w.Stats()
+	assert.Equal(t, int64(2), stats.Failed)
+	assert.True(t, stats.Idle > 0 && stats.Idle < 20, "idle jobs: %d", stats.Idle)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&jobs))
+	assert.Equal(t, int64(2), atomic.LoadInt64(&jobErrors))
+
+	assert.NoError(t, w.Shutdown(context.Background()))
+}
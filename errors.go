@@ -0,0 +1,67 @@
+package porter
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrorClass is a category of the job result, it defines the delay after the job
+type ErrorClass string
+
+const (
+	// ClassSuccess is the class of the job that returned no error
+	ClassSuccess ErrorClass = "success"
+	// ClassIdle is the class of the job that returned ErrIdleJob or was not performed because of ErrCircuitOpen
+	ClassIdle ErrorClass = "idle"
+	// ClassError is the class of the job that returned an error without a registered class
+	ClassError ErrorClass = "error"
+)
+
+type errorClass struct {
+	class   ErrorClass
+	target  error
+	timeout time.Duration
+}
+
+// WithErrorClass adds a delay after the job that returned an error matching the target by errors.Is,
+// the error is reported with the class named after the target
+func WithErrorClass(target error, timeout time.Duration) Opt {
+	return func(w *worker) {
+		if target == nil {
+			return
+		}
+
+		w.config.errorClasses = append(w.config.errorClasses, errorClass{
+			class:   ErrorClass(target.Error()),
+			target:  target,
+			timeout: timeout,
+		})
+	}
+}
+
+// ErrorClassFromState returns the class of the job error according to the classes registered in the worker
+func ErrorClassFromState(state State, err error) ErrorClass {
+	var classes []errorClass
+	if sl := slotFromContext(state.Context()); sl != nil {
+		classes = sl.pool.config.errorClasses
+	}
+
+	return classifyError(classes, err).class
+}
+
+func classifyError(classes []errorClass, err error) errorClass {
+	switch {
+	case err == nil:
+		return errorClass{class: ClassSuccess}
+	case errors.Is(err, ErrIdleJob), errors.Is(err, ErrCircuitOpen):
+		return errorClass{class: ClassIdle}
+	}
+
+	for _, c := range classes {
+		if errors.Is(err, c.target) {
+			return c
+		}
+	}
+
+	return errorClass{class: ClassError}
+}
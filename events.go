@@ -1,35 +1,302 @@
 package porter
 
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// JobResult describes a finished job
+type JobResult struct {
+	RunningJob
+	// Time spent on the job
+	Duration time.Duration
+	// Class of the job error, ClassSuccess if the job returned nil
+	Class ErrorClass
+	Err   error
+}
+
+type eventKind int
+
+const (
+	runEvent eventKind = iota
+	shutdownEvent
+	pauseEvent
+	resumeEvent
+	jobErrorEvent
+	restartEvent
+	jobStartEvent
+	jobFinishEvent
+	jobPanicEvent
+	jobTimeoutEvent
+	handlerPanicEvent
+	circuitChangeEvent
+	jobOverrunEvent
+)
+
+// subscription is compared by pointer, so the same handler can be subscribed and unsubscribed several times
+type subscription struct {
+	kind    eventKind
+	handler interface{}
+	// Pending calls of the handler if the events are delivered asynchronously
+	queue chan func()
+	// The handler is called right away regardless of the delivery, e.g. by the event stream
+	direct bool
+	// 1 while a goroutine delivers the queued calls
+	draining int32
+	// The channel is closed when the handler is unsubscribed
+	stop     chan struct{}
+	overflow OverflowPolicy
+}
+
+type subscriptions map[eventKind][]*subscription
+
+// Dispatcher delivers the events to the handlers, it is safe for concurrent use
 type Dispatcher struct {
-	onRunHandlers      errorHandlers
-	onShutdownHandlers errorHandlers
+	// Serializes the changes of the subscriptions
+	mu sync.Mutex
+	// The subscriptions are copied on write, so the events are dispatched without locking
+	subscriptions atomic.Value
+	// Delivers the events asynchronously if not nil, it is set before the first subscription
+	async *asyncDelivery
+	// Number of the events dropped because of the queue overflow
+	dropped int64
 }
 
+// Subscriber subscribes to the events, every Listen method returns a function that removes the handlers
 type Subscriber interface {
-	ListenRun(handlers ...func(error))
-	ListenShutdown(handlers ...func(error))
+	ListenRun(handlers ...func(error)) func()
+	ListenShutdown(handlers ...func(error)) func()
+	ListenPause(handlers ...func(error)) func()
+	ListenResume(handlers ...func(error)) func()
+	// ListenJobError listens to the jobs that returned an error, except the errors of ClassIdle
+	ListenJobError(handlers ...func(ErrorClass, error)) func()
+	// ListenRestart listens to the restarts of the workers by the supervised group
+	ListenRestart(handlers ...func(Restart)) func()
+	// ListenJobStart listens to the jobs started by the worker, the job ID is not known yet at the start
+	ListenJobStart(handlers ...func(RunningJob)) func()
+	// ListenJobFinish listens to the results of all the jobs, including the idle ones
+	ListenJobFinish(handlers ...func(JobResult)) func()
+	// ListenJobPanic listens to the job panics, both recovered by RecoverMiddleware and crashing the worker
+	ListenJobPanic(handlers ...func(RunningJob, error)) func()
+	// ListenJobTimeout listens to the jobs that returned context.DeadlineExceeded, e.g. because of JobTTLMiddleware
+	ListenJobTimeout(handlers ...func(RunningJob, error)) func()
+	// ListenHandlerPanic listens to the panics of the handlers delivered asynchronously, see WithAsyncEvents
+	ListenHandlerPanic(handlers ...func(error)) func()
+	// ListenCircuitChange listens to the state changes of the circuit breakers, see CircuitBreakerMiddleware
+	ListenCircuitChange(handlers ...func(CircuitChange)) func()
+	// ListenJobOverrun listens to the jobs that didn't return within the TTL of JobTTLMiddleware
+	ListenJobOverrun(handlers ...func(JobOverrun)) func()
 }
 
 func (d *Dispatcher) OnRun(err error) {
-	d.onRunHandlers.Invoke(err)
+	for _, s := range d.handlers(runEvent) {
+		h := s.handler.(func(error))
+		d.deliver(s, func() { h(err) })
+	}
 }
 
 func (d *Dispatcher) OnShutdown(err error) {
-	d.onShutdownHandlers.Invoke(err)
+	for _, s := range d.handlers(shutdownEvent) {
+		h := s.handler.(func(error))
+		d.deliver(s, func() { h(err) })
+	}
 }
 
-func (d *Dispatcher) ListenRun(handlers ...func(error)) {
-	d.onRunHandlers = append(d.onRunHandlers, handlers...)
+func (d *Dispatcher) OnPause(err error) {
+	for _, s := range d.handlers(pauseEvent) {
+		h := s.handler.(func(error))
+		d.deliver(s, func() { h(err) })
+	}
 }
 
-func (d *Dispatcher) ListenShutdown(handlers ...func(error)) {
-	d.onShutdownHandlers = append(d.onShutdownHandlers, handlers...)
+func (d *Dispatcher) OnResume(err error) {
+	for _, s := range d.handlers(resumeEvent) {
+		h := s.handler.(func(error))
+		d.deliver(s, func() { h(err) })
+	}
+}
+
+func (d *Dispatcher) OnJobError(class ErrorClass, err error) {
+	for _, s := range d.handlers(jobErrorEvent) {
+		h := s.handler.(func(ErrorClass, error))
+		d.deliver(s, func() { h(class, err) })
+	}
+}
+
+func (d *Dispatcher) OnRestart(restart Restart) {
+	for _, s := range d.handlers(restartEvent) {
+		h := s.handler.(func(Restart))
+		d.deliver(s, func() { h(restart) })
+	}
 }
 
-type errorHandlers []func(error)
+func (d *Dispatcher) OnJobStart(job RunningJob) {
+	for _, s := range d.handlers(jobStartEvent) {
+		h := s.handler.(func(RunningJob))
+		d.deliver(s, func() { h(job) })
+	}
+}
 
-func (h errorHandlers) Invoke(err error) {
-	for _, handler := range h {
-		handler(err)
+func (d *Dispatcher) OnJobFinish(result JobResult) {
+	for _, s := range d.handlers(jobFinishEvent) {
+		h := s.handler.(func(JobResult))
+		d.deliver(s, func() { h(result) })
 	}
 }
+
+func (d *Dispatcher) OnJobPanic(job RunningJob, err error) {
+	for _, s := range d.handlers(jobPanicEvent) {
+		h := s.handler.(func(RunningJob, error))
+		d.deliver(s, func() { h(job, err) })
+	}
+}
+
+func (d *Dispatcher) OnJobTimeout(job RunningJob, err error) {
+	for _, s := range d.handlers(jobTimeoutEvent) {
+		h := s.handler.(func(RunningJob, error))
+		d.deliver(s, func() { h(job, err) })
+	}
+}
+
+// OnHandlerPanic is emitted by the dispatcher when a handler delivered asynchronously panics
+func (d *Dispatcher) OnHandlerPanic(err error) {
+	for _, s := range d.handlers(handlerPanicEvent) {
+		h := s.handler.(func(error))
+		d.deliver(s, func() { h(err) })
+	}
+}
+
+// OnCircuitChange is emitted by CircuitBreakerMiddleware through the worker that performed the job
+func (d *Dispatcher) OnCircuitChange(change CircuitChange) {
+	for _, s := range d.handlers(circuitChangeEvent) {
+		h := s.handler.(func(CircuitChange))
+		d.deliver(s, func() { h(change) })
+	}
+}
+
+// OnJobOverrun is emitted by JobTTLMiddleware and JobDeadlineMiddleware through the worker that performed the job
+func (d *Dispatcher) OnJobOverrun(overrun JobOverrun) {
+	for _, s := range d.handlers(jobOverrunEvent) {
+		h := s.handler.(func(JobOverrun))
+		d.deliver(s, func() { h(overrun) })
+	}
+}
+
+func (d *Dispatcher) ListenRun(handlers ...func(error)) func() {
+	return d.subscribe(runEvent, true, len(handlers), func(i int) interface{} { return handlers[i] })
+}
+
+func (d *Dispatcher) ListenShutdown(handlers ...func(error)) func() {
+	return d.subscribe(shutdownEvent, true, len(handlers), func(i int) interface{} { return handlers[i] })
+}
+
+func (d *Dispatcher) ListenPause(handlers ...func(error)) func() {
+	return d.subscribe(pauseEvent, true, len(handlers), func(i int) interface{} { return handlers[i] })
+}
+
+func (d *Dispatcher) ListenResume(handlers ...func(error)) func() {
+	return d.subscribe(resumeEvent, true, len(handlers), func(i int) interface{} { return handlers[i] })
+}
+
+func (d *Dispatcher) ListenJobError(handlers ...func(ErrorClass, error)) func() {
+	return d.subscribe(jobErrorEvent, true, len(handlers), func(i int) interface{} { return handlers[i] })
+}
+
+func (d *Dispatcher) ListenRestart(handlers ...func(Restart)) func() {
+	return d.subscribe(restartEvent, true, len(handlers), func(i int) interface{} { return handlers[i] })
+}
+
+func (d *Dispatcher) ListenJobStart(handlers ...func(RunningJob)) func() {
+	return d.subscribe(jobStartEvent, true, len(handlers), func(i int) interface{} { return handlers[i] })
+}
+
+func (d *Dispatcher) ListenJobFinish(handlers ...func(JobResult)) func() {
+	return d.subscribe(jobFinishEvent, true, len(handlers), func(i int) interface{} { return handlers[i] })
+}
+
+func (d *Dispatcher) ListenJobPanic(handlers ...func(RunningJob, error)) func() {
+	return d.subscribe(jobPanicEvent, true, len(handlers), func(i int) interface{} { return handlers[i] })
+}
+
+func (d *Dispatcher) ListenJobTimeout(handlers ...func(RunningJob, error)) func() {
+	return d.subscribe(jobTimeoutEvent, true, len(handlers), func(i int) interface{} { return handlers[i] })
+}
+
+func (d *Dispatcher) ListenHandlerPanic(handlers ...func(error)) func() {
+	return d.subscribe(handlerPanicEvent, true, len(handlers), func(i int) interface{} { return handlers[i] })
+}
+
+func (d *Dispatcher) ListenCircuitChange(handlers ...func(CircuitChange)) func() {
+	return d.subscribe(circuitChangeEvent, true, len(handlers), func(i int) interface{} { return handlers[i] })
+}
+
+func (d *Dispatcher) ListenJobOverrun(handlers ...func(JobOverrun)) func() {
+	return d.subscribe(jobOverrunEvent, true, len(handlers), func(i int) interface{} { return handlers[i] })
+}
+
+// handlers returns the current subscriptions to the event, the slice must not be modified
+func (d *Dispatcher) handlers(kind eventKind) []*subscription {
+	subs, _ := d.subscriptions.Load().(subscriptions)
+	return subs[kind]
+}
+
+// subscribe adds n handlers returned by the handler function and returns the function that removes them,
+// the handlers subscribed during the dispatching of the event receive the next events.
+// If async is false, the handlers are called right away even if the delivery is asynchronous
+func (d *Dispatcher) subscribe(kind eventKind, async bool, n int, handler func(i int) interface{}) func() {
+	subs := make([]*subscription, n)
+	for i := range subs {
+		subs[i] = d.newSubscription(kind, async, handler(i))
+	}
+
+	d.update(func(current subscriptions) {
+		current[kind] = append(current[kind][:len(current[kind]):len(current[kind])], subs...)
+	})
+
+	once := sync.Once{}
+
+	return func() {
+		once.Do(func() {
+			d.update(func(current subscriptions) {
+				remaining := make([]*subscription, 0, len(current[kind]))
+				for _, s := range current[kind] {
+					if !containsSubscription(subs, s) {
+						remaining = append(remaining, s)
+					}
+				}
+				current[kind] = remaining
+			})
+
+			for _, s := range subs {
+				close(s.stop)
+			}
+		})
+	}
+}
+
+// update changes a copy of the subscriptions and publishes it
+func (d *Dispatcher) update(fn func(subscriptions)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	current, _ := d.subscriptions.Load().(subscriptions)
+
+	next := make(subscriptions, len(current)+1)
+	for kind, subs := range current {
+		next[kind] = subs
+	}
+
+	fn(next)
+	d.subscriptions.Store(next)
+}
+
+func containsSubscription(subs []*subscription, s *subscription) bool {
+	for _, sub := range subs {
+		if sub == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,195 @@
+package porter
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+const defaultEventsQueueSize = 64
+
+// OverflowPolicy defines what happens to the event when the queue of the subscriber is full
+type OverflowPolicy int
+
+const (
+	// DropOnOverflow drops the event, so a slow handler never slows the worker down
+	DropOnOverflow OverflowPolicy = iota
+	// BlockOnOverflow blocks the worker until the handler takes the event
+	BlockOnOverflow
+)
+
+type asyncDelivery struct {
+	queueSize int
+	overflow  OverflowPolicy
+}
+
+// WithAsyncEvents delivers the events to every handler on its own goroutine through a queue of the given size,
+// so a slow or panicking handler doesn't block or crash the worker. The panics of the handlers are reported
+// by ListenHandlerPanic, Shutdown waits for the queued events to be delivered within its context
+func WithAsyncEvents(queueSize int, overflow OverflowPolicy) Opt {
+	return func(w *worker) {
+		w.events.setAsync(queueSize, overflow)
+	}
+}
+
+// WithGroupAsyncEvents delivers the events of the group asynchronously, see WithAsyncEvents
+func WithGroupAsyncEvents(queueSize int, overflow OverflowPolicy) GroupOpt {
+	return func(g *workerGroup) {
+		g.events.setAsync(queueSize, overflow)
+	}
+}
+
+// setAsync switches the dispatcher to the asynchronous delivery, it must be called before the events are emitted
+func (d *Dispatcher) setAsync(queueSize int, overflow OverflowPolicy) {
+	if queueSize <= 0 {
+		queueSize = defaultEventsQueueSize
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.async = &asyncDelivery{queueSize: queueSize, overflow: overflow}
+
+	// the handlers subscribed by the preceding options are delivered asynchronously too
+	current, _ := d.subscriptions.Load().(subscriptions)
+	for _, subs := range current {
+		for _, s := range subs {
+			if s.queue == nil && !s.direct {
+				d.makeQueue(s)
+			}
+		}
+	}
+}
+
+// newSubscription creates the subscription, the handler gets its own queue if both the delivery and the handler are asynchronous
+func (d *Dispatcher) newSubscription(kind eventKind, async bool, handler interface{}) *subscription {
+	s := &subscription{
+		kind:    kind,
+		handler: handler,
+		direct:  !async,
+		stop:    make(chan struct{}),
+	}
+
+	d.mu.Lock()
+	if d.async != nil && async {
+		d.makeQueue(s)
+	}
+	d.mu.Unlock()
+
+	return s
+}
+
+// makeQueue must be called under mu before the subscription is published
+func (d *Dispatcher) makeQueue(s *subscription) {
+	s.queue = make(chan func(), d.async.queueSize)
+	s.overflow = d.async.overflow
+}
+
+// deliver calls the handler right away or puts the call into the queue of the handler
+func (d *Dispatcher) deliver(s *subscription, call func()) {
+	if s.queue == nil {
+		call()
+		return
+	}
+
+	if s.overflow == BlockOnOverflow {
+		select {
+		case s.queue <- call:
+		case <-s.stop:
+			return
+		}
+	} else {
+		select {
+		case s.queue <- call:
+		case <-s.stop:
+			return
+		default:
+			atomic.AddInt64(&d.dropped, 1)
+			return
+		}
+	}
+
+	d.drain(s)
+}
+
+// drain starts the goroutine that delivers the queued calls unless it is already running,
+// the goroutine exits when the queue is empty, so the idle handlers and the stopped workers hold no goroutines
+func (d *Dispatcher) drain(s *subscription) {
+	if atomic.CompareAndSwapInt32(&s.draining, 0, 1) {
+		go d.listen(s)
+	}
+}
+
+// listen calls the queued handler until the queue is empty or the handler is unsubscribed
+func (d *Dispatcher) listen(s *subscription) {
+	for {
+		select {
+		case call := <-s.queue:
+			d.call(s, call)
+			continue
+		case <-s.stop:
+			return
+		default:
+		}
+
+		// the call queued after the queue was found empty is delivered by this goroutine or by the one started by drain
+		atomic.StoreInt32(&s.draining, 0)
+		if len(s.queue) == 0 || !atomic.CompareAndSwapInt32(&s.draining, 0, 1) {
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) call(s *subscription, call func()) {
+	defer func() {
+		if r := recover(); r != nil && s.kind != handlerPanicEvent {
+			d.OnHandlerPanic(newPanicError(r))
+		}
+	}()
+
+	call()
+}
+
+// Dropped returns the number of the events dropped because the queues of the handlers or the channel of Events were full
+func (d *Dispatcher) Dropped() int64 {
+	return atomic.LoadInt64(&d.dropped)
+}
+
+// Flush waits until the events emitted so far are delivered to the current handlers
+func (d *Dispatcher) Flush(ctx context.Context) error {
+	current, _ := d.subscriptions.Load().(subscriptions)
+
+	type marker struct {
+		done chan struct{}
+		stop <-chan struct{}
+	}
+
+	var markers []marker
+	for _, subs := range current {
+		for _, s := range subs {
+			if s.queue == nil {
+				continue
+			}
+
+			done := make(chan struct{})
+			select {
+			case s.queue <- func() { close(done) }:
+				markers = append(markers, marker{done: done, stop: s.stop})
+				d.drain(s)
+			case <-s.stop:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+
+	for _, m := range markers {
+		select {
+		case <-m.done:
+		case <-m.stop:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
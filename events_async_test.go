@@ -0,0 +1,177 @@
+package porter
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcher_Async(t *testing.T) {
+	t.Run("DropOnOverflow", func(t *testing.T) {
+		d := &Dispatcher{}
+		d.setAsync(1, DropOnOverflow)
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		var calls int64
+		d.ListenRun(func(_ error) {
+			if atomic.AddInt64(&calls, 1) == 1 {
+				close(started)
+			}
+			<-release
+		})
+
+		d.OnRun(nil)
+		<-started
+
+		// the first event is queued, the rest are dropped
+		d.OnRun(nil)
+		d.OnRun(nil)
+		d.OnRun(nil)
+		assert.Equal(t, int64(2), d.Dropped())
+
+		close(release)
+		assert.NoError(t, d.Flush(context.Background()))
+		assert.Equal(t, int64(2), atomic.LoadInt64(&calls))
+	})
+
+	t.Run("BlockOnOverflow", func(t *testing.T) {
+		d := &Dispatcher{}
+		d.setAsync(1, BlockOnOverflow)
+
+		release := make(chan struct{})
+		var calls int64
+		d.ListenRun(func(_ error) {
+			<-release
+			atomic.AddInt64(&calls, 1)
+		})
+
+		emitted := make(chan struct{})
+		go func() {
+			d.OnRun(nil)
+			d.OnRun(nil)
+			d.OnRun(nil)
+			close(emitted)
+		}()
+
+		select {
+		case <-emitted:
+			t.Fatal("the events must wait for the room in the queue")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		close(release)
+		<-emitted
+		assert.NoError(t, d.Flush(context.Background()))
+		assert.Equal(t, int64(3), atomic.LoadInt64(&calls))
+		assert.Equal(t, int64(0), d.Dropped())
+	})
+
+	t.Run("HandlerPanic", func(t *testing.T) {
+		d := &Dispatcher{}
+		d.setAsync(10, DropOnOverflow)
+
+		panics := make(chan error, 10)
+		d.ListenHandlerPanic(func(err error) {
+			panics <- err
+			panic("must not be reported")
+		})
+		d.ListenRun(func(_ error) {
+			panic("test panic")
+		})
+
+		d.OnRun(nil)
+		assert.Contains(t, (<-panics).Error(), "porter: panic test panic")
+
+		assert.NoError(t, d.Flush(context.Background()))
+		assert.Len(t, panics, 0)
+	})
+
+	t.Run("Unsubscribe", func(t *testing.T) {
+		d := &Dispatcher{}
+		d.setAsync(10, BlockOnOverflow)
+
+		release := make(chan struct{})
+		unsubscribe := d.ListenRun(func(_ error) {
+			<-release
+		})
+
+		d.OnRun(nil)
+		d.OnRun(nil)
+		unsubscribe()
+
+		// the events of the removed handler are not waited for
+		assert.NoError(t, d.Flush(context.Background()))
+		close(release)
+	})
+}
+
+func TestWorker_AsyncEvents(t *testing.T) {
+	var shutdown int64
+	release := make(chan struct{})
+
+	w := NewWorker(
+		func(state State) error {
+			return nil
+		},
+		WithSuccessTimeout(1*time.Second),
+		WithSubscriber(func(s Subscriber) {
+			s.ListenRun(func(_ error) {
+				<-release
+			})
+			s.ListenShutdown(func(_ error) {
+				time.Sleep(50 * time.Millisecond)
+				atomic.AddInt64(&shutdown, 1)
+			})
+		}),
+		WithAsyncEvents(10, BlockOnOverflow),
+	)
+
+	// the slow handler doesn't block the worker
+	assert.NoError(t, w.Run())
+	close(release)
+
+	// the shutdown event is delivered before Shutdown returns
+	assert.NoError(t, w.Shutdown(context.Background()))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&shutdown))
+}
+
+func TestWorker_AsyncEventsStopped(t *testing.T) {
+	var calls int64
+
+	for i := 0; i < 50; i++ {
+		w := NewWorker(
+			func(state State) error {
+				return nil
+			},
+			WithSuccessTimeout(1*time.Second),
+			WithSubscriber(func(s Subscriber) {
+				s.ListenRun(func(_ error) {
+					atomic.AddInt64(&calls, 1)
+				})
+				s.ListenShutdown(func(_ error) {
+					atomic.AddInt64(&calls, 1)
+				})
+			}),
+			WithAsyncEvents(10, DropOnOverflow),
+		)
+
+		assert.NoError(t, w.Run())
+		assert.NoError(t, w.Shutdown(context.Background()))
+	}
+
+	assert.Equal(t, int64(100), atomic.LoadInt64(&calls))
+
+	// the stopped workers don't hold the goroutines of the queues
+	assert.Eventually(t, func() bool {
+		buf := make([]byte, 1<<20)
+		buf = buf[:runtime.Stack(buf, true)]
+
+		return !strings.Contains(string(buf), "porter.(*Dispatcher).listen")
+	}, time.Second, 10*time.Millisecond)
+}
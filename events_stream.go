@@ -0,0 +1,197 @@
+package porter
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultEventsBuffer = 64
+
+// EventType is the type of the event in the stream returned by Events
+type EventType int
+
+const (
+	EventRun EventType = iota
+	EventShutdown
+	EventPause
+	EventResume
+	EventJobError
+	EventRestart
+	EventJobStart
+	EventJobFinish
+	EventJobPanic
+	EventJobTimeout
+	EventHandlerPanic
+	EventCircuitChange
+	EventJobOverrun
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventRun:
+		return "run"
+	case EventShutdown:
+		return "shutdown"
+	case EventPause:
+		return "pause"
+	case EventResume:
+		return "resume"
+	case EventJobError:
+		return "job_error"
+	case EventRestart:
+		return "restart"
+	case EventJobStart:
+		return "job_start"
+	case EventJobFinish:
+		return "job_finish"
+	case EventJobPanic:
+		return "job_panic"
+	case EventJobTimeout:
+		return "job_timeout"
+	case EventHandlerPanic:
+		return "handler_panic"
+	case EventCircuitChange:
+		return "circuit_change"
+	case EventJobOverrun:
+		return "job_overrun"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a value delivered by the stream, only the fields related to the type are set
+type Event struct {
+	Type EventType
+	// Time when the event was emitted
+	Time time.Time
+	// Name of the worker set by WithName or of the group set by WithGroupName
+	Worker string
+	// The error of the lifecycle event or the job
+	Err error
+	// The job of the job events
+	Job RunningJob
+	// Time spent on the job, set for EventJobFinish and EventJobOverrun
+	Duration time.Duration
+	// Class of the job result, set for EventJobError and EventJobFinish
+	Class ErrorClass
+	// Set for EventRestart
+	Restart Restart
+	// Set for EventCircuitChange
+	Circuit CircuitChange
+	// Set for EventJobOverrun
+	Overrun JobOverrun
+}
+
+// WithName sets the name of the worker that is added to the events of the stream
+func WithName(name string) Opt {
+	return func(w *worker) {
+		w.stream.name = name
+	}
+}
+
+// WithEventsBuffer sets the size of the channel returned by Events, the events that don't fit are dropped
+func WithEventsBuffer(size int) Opt {
+	return func(w *worker) {
+		if size > 0 {
+			w.stream.size = size
+		}
+	}
+}
+
+// WithGroupName sets the name of the group that is added to the events of the stream
+func WithGroupName(name string) GroupOpt {
+	return func(g *workerGroup) {
+		g.stream.name = name
+	}
+}
+
+// eventStream converts the events of the dispatcher into values of the channel
+type eventStream struct {
+	name string
+	size int
+
+	once   sync.Once
+	events chan Event
+}
+
+// channel subscribes to all the events on the first call and returns the same channel on every call
+func (s *eventStream) channel(d *Dispatcher) <-chan Event {
+	s.once.Do(func() {
+		size := s.size
+		if size <= 0 {
+			size = defaultEventsBuffer
+		}
+
+		s.events = make(chan Event, size)
+		s.subscribe(d)
+	})
+
+	return s.events
+}
+
+// subscribe listens to the events directly, bypassing the queues of WithAsyncEvents, since send never blocks.
+// So the events are timestamped when they are emitted and keep their order in the channel
+func (s *eventStream) subscribe(d *Dispatcher) {
+	listen := func(kind eventKind, handler interface{}) {
+		d.subscribe(kind, false, 1, func(int) interface{} { return handler })
+	}
+
+	listen(runEvent, func(err error) {
+		s.send(d, Event{Type: EventRun, Err: err})
+	})
+	listen(shutdownEvent, func(err error) {
+		s.send(d, Event{Type: EventShutdown, Err: err})
+	})
+	listen(pauseEvent, func(err error) {
+		s.send(d, Event{Type: EventPause, Err: err})
+	})
+	listen(resumeEvent, func(err error) {
+		s.send(d, Event{Type: EventResume, Err: err})
+	})
+	listen(jobErrorEvent, func(class ErrorClass, err error) {
+		s.send(d, Event{Type: EventJobError, Class: class, Err: err})
+	})
+	listen(restartEvent, func(restart Restart) {
+		s.send(d, Event{Type: EventRestart, Restart: restart, Err: restart.Err})
+	})
+	listen(jobStartEvent, func(job RunningJob) {
+		s.send(d, Event{Type: EventJobStart, Job: job})
+	})
+	listen(jobFinishEvent, func(result JobResult) {
+		s.send(d, Event{
+			Type:     EventJobFinish,
+			Job:      result.RunningJob,
+			Duration: result.Duration,
+			Class:    result.Class,
+			Err:      result.Err,
+		})
+	})
+	listen(jobPanicEvent, func(job RunningJob, err error) {
+		s.send(d, Event{Type: EventJobPanic, Job: job, Err: err})
+	})
+	listen(jobTimeoutEvent, func(job RunningJob, err error) {
+		s.send(d, Event{Type: EventJobTimeout, Job: job, Err: err})
+	})
+	listen(handlerPanicEvent, func(err error) {
+		s.send(d, Event{Type: EventHandlerPanic, Err: err})
+	})
+	listen(circuitChangeEvent, func(change CircuitChange) {
+		s.send(d, Event{Type: EventCircuitChange, Circuit: change})
+	})
+	listen(jobOverrunEvent, func(overrun JobOverrun) {
+		s.send(d, Event{Type: EventJobOverrun, Job: overrun.RunningJob, Duration: overrun.Duration, Overrun: overrun})
+	})
+}
+
+// send never blocks the worker, the event is dropped if the channel is full
+func (s *eventStream) send(d *Dispatcher, e Event) {
+	e.Time = time.Now()
+	e.Worker = s.name
+
+	select {
+	case s.events <- e:
+	default:
+		atomic.AddInt64(&d.dropped, 1)
+	}
+}
@@ -0,0 +1,101 @@
+package porter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorker_Events(t *testing.T) {
+	t.Run("Stream", func(t *testing.T) {
+		errTest := errors.New("test error")
+
+		w := NewWorker(
+			func(state State) error {
+				return errTest
+			},
+			WithName("importer"),
+			WithErrorTimeout(1*time.Second),
+		)
+
+		events := w.Events()
+		assert.Equal(t, events, w.Events())
+
+		before := time.Now()
+		assert.NoError(t, w.Run())
+
+		var received []Event
+		for len(received) < 4 {
+			received = append(received, <-events)
+		}
+
+		var types []EventType
+		for _, e := range received {
+			types = append(types, e.Type)
+			assert.Equal(t, "importer", e.Worker)
+			assert.False(t, e.Time.Before(before))
+		}
+		assert.Equal(t, []EventType{EventRun, EventJobStart, EventJobError, EventJobFinish}, types)
+
+		finish := received[3]
+		assert.Equal(t, ClassError, finish.Class)
+		assert.Equal(t, errTest, finish.Err)
+		assert.Equal(t, 0, finish.Job.Executor)
+
+		assert.NoError(t, w.Shutdown(context.Background()))
+		assert.Equal(t, EventShutdown, (<-events).Type)
+	})
+
+	t.Run("AsyncEvents", func(t *testing.T) {
+		errTest := errors.New("test error")
+
+		w := NewWorker(
+			func(state State) error {
+				return errTest
+			},
+			WithErrorTimeout(1*time.Millisecond),
+			WithAsyncEvents(10, DropOnOverflow),
+		)
+
+		events := w.Events()
+		assert.NoError(t, w.Run())
+
+		// the stream is not delivered through the queues, so the events keep the order of emitting
+		var previous Event
+		expected := []EventType{EventJobStart, EventJobError, EventJobFinish}
+		for i := 0; i < 30; i++ {
+			e := <-events
+			if e.Type == EventRun {
+				continue
+			}
+
+			assert.Equal(t, expected[0], e.Type)
+			assert.False(t, e.Time.Before(previous.Time))
+			expected = append(expected[1:], expected[0])
+			previous = e
+		}
+
+		assert.NoError(t, w.Shutdown(context.Background()))
+	})
+
+	t.Run("Overflow", func(t *testing.T) {
+		w := NewWorker(
+			func(state State) error {
+				return nil
+			},
+			WithEventsBuffer(1),
+			WithSuccessTimeout(1*time.Second),
+		)
+
+		events := w.Events()
+		assert.NoError(t, w.Run())
+		assert.NoError(t, w.Shutdown(context.Background()))
+
+		assert.Equal(t, EventRun, (<-events).Type)
+		assert.Len(t, events, 0)
+		assert.True(t, w.Subscriber().(*Dispatcher).Dropped() > 0)
+	})
+}
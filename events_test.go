@@ -0,0 +1,89 @@
+package porter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcher_Unsubscribe(t *testing.T) {
+	d := &Dispatcher{}
+
+	var first, second int
+	unsubscribe := d.ListenRun(func(_ error) {
+		first++
+	})
+	d.ListenRun(func(_ error) {
+		second++
+	})
+
+	d.OnRun(nil)
+	unsubscribe()
+	unsubscribe()
+	d.OnRun(nil)
+
+	assert.Equal(t, 1, first)
+	assert.Equal(t, 2, second)
+}
+
+func TestDispatcher_SubscribeOnEvent(t *testing.T) {
+	d := &Dispatcher{}
+
+	var calls []string
+	var unsubscribe func()
+	unsubscribe = d.ListenJobError(func(_ ErrorClass, _ error) {
+		calls = append(calls, "first")
+		unsubscribe()
+
+		d.ListenJobError(func(_ ErrorClass, _ error) {
+			calls = append(calls, "second")
+		})
+	})
+
+	d.OnJobError(ClassError, errors.New("test error"))
+	d.OnJobError(ClassError, errors.New("test error"))
+
+	assert.Equal(t, []string{"first", "second"}, calls)
+}
+
+func TestWorker_Subscriber(t *testing.T) {
+	w := NewWorker(
+		func(state State) error {
+			return nil
+		},
+		WithJobsLimit(4),
+	)
+
+	assert.NoError(t, w.Run())
+
+	var jobs int64
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			unsubscribe := w.Subscriber().ListenJobFinish(func(_ JobResult) {
+				atomic.AddInt64(&jobs, 1)
+			})
+			time.Sleep(10 * time.Millisecond)
+			unsubscribe()
+		}()
+	}
+	wg.Wait()
+
+	assert.True(t, atomic.LoadInt64(&jobs) > 0)
+
+	shutdown := make(chan error, 1)
+	w.Subscriber().ListenShutdown(func(err error) {
+		shutdown <- err
+	})
+
+	assert.NoError(t, w.Shutdown(context.Background()))
+	assert.NoError(t, <-shutdown)
+}
@@ -21,6 +21,9 @@ func main() {
 					fmt.Println("worker is running")
 				}
 			})
+			s.ListenJobFinish(func(result porter.JobResult) {
+				fmt.Println("job finished", result.ID, result.Duration, result.Class)
+			})
 		}),
 		porter.WithMiddleware(
 			porter.JobIDMiddleware(),
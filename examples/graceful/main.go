@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/moriony/go-porter"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	w := porter.NewWorker(
+		func(state porter.State) error {
+			select {
+			case <-time.After(2 * time.Second):
+				fmt.Println("job finished")
+			case <-state.Context().Done():
+				// the worker is shutting down and the grace period is over
+				fmt.Println("job cancelled")
+			}
+
+			return nil
+		},
+		porter.WithShutdownGracePeriod(1*time.Second),
+	)
+
+	// the worker is shut down on the first interrupt
+	err := w.RunContext(ctx)
+	if err != nil {
+		fmt.Println("error", err)
+		return
+	}
+
+	<-w.Done()
+	fmt.Println("worker stopped")
+}
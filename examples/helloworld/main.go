@@ -17,7 +17,6 @@ func main() {
 		helloFunc,
 		porter.WithJobsLimit(1),
 		porter.WithSuccessTimeout(500*time.Millisecond),
-		porter.WithShutdownPollTimeout(100*time.Millisecond),
 		porter.WithMiddleware(
 			porter.JobTTLMiddleware(2*time.Second),
 		),
@@ -0,0 +1,152 @@
+package porter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrUnnamedMember     = errors.New("member name is empty")
+	ErrUnknownMember     = errors.New("unknown member")
+	ErrMemberInUse       = errors.New("member is a dependency")
+	ErrDuplicateMember   = errors.New("duplicate member name")
+	ErrUnknownDependency = errors.New("unknown dependency")
+	ErrDependencyCycle   = errors.New("dependency cycle")
+)
+
+// member is a worker of the group
+type member struct {
+	// Unique name of the member, unnamed members can't be dependencies
+	name   string
+	worker Worker
+	// Names of the members that are started before and stopped after the member
+	dependsOn []string
+}
+
+// WithMember adds the named worker to the group,
+// it is started after the workers it depends on and is stopped before them
+func WithMember(name string, w Worker, dependsOn ...string) GroupOpt {
+	return func(g *workerGroup) {
+		g.members = append(g.members, &member{
+			name:      name,
+			worker:    w,
+			dependsOn: dependsOn,
+		})
+	}
+}
+
+// sortMembers orders the members so that every member follows its dependencies,
+// the members without dependencies between them keep the declaration order
+func sortMembers(members []*member) ([]*member, error) {
+	byName := make(map[string]*member, len(members))
+	for _, m := range members {
+		if m.name == "" {
+			continue
+		}
+		if _, ok := byName[m.name]; ok {
+			return nil, fmt.Errorf("%w: %q", ErrDuplicateMember, m.name)
+		}
+		byName[m.name] = m
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	states := make(map[*member]int, len(members))
+	sorted := make([]*member, 0, len(members))
+	var path []string
+
+	var visit func(m *member) error
+	visit = func(m *member) error {
+		switch states[m] {
+		case visited:
+			return nil
+		case visiting:
+			cycle := append(path[indexOf(path, m.name):], m.name)
+			return fmt.Errorf("%w: %s", ErrDependencyCycle, strings.Join(cycle, " -> "))
+		}
+
+		states[m] = visiting
+		path = append(path, m.name)
+
+		for _, name := range m.dependsOn {
+			dep, ok := byName[name]
+			if !ok {
+				return fmt.Errorf("%w: %q depends on %q", ErrUnknownDependency, m.name, name)
+			}
+
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+
+		path = path[:len(path)-1]
+		states[m] = visited
+		sorted = append(sorted, m)
+
+		return nil
+	}
+
+	for _, m := range members {
+		if err := visit(m); err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
+}
+
+func indexOf(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+
+	return 0
+}
+
+// stopMembers calls fn for the members concurrently,
+// but not before fn has returned for all the members that depend on the member
+func stopMembers(members []*member, fn func(Worker) error) error {
+	// the channel of the member is closed after fn has returned for it
+	stopped := make([]chan struct{}, len(members))
+	// channels of the members that depend on the named member
+	dependents := make(map[string][]chan struct{}, len(members))
+
+	for i, m := range members {
+		stopped[i] = make(chan struct{})
+		for _, name := range m.dependsOn {
+			dependents[name] = append(dependents[name], stopped[i])
+		}
+	}
+
+	errs := make([]error, len(members))
+
+	wg := sync.WaitGroup{}
+	wg.Add(len(members))
+
+	for i, m := range members {
+		go func(i int, m *member) {
+			defer wg.Done()
+			defer close(stopped[i])
+
+			if m.name != "" {
+				for _, ch := range dependents[m.name] {
+					<-ch
+				}
+			}
+
+			errs[i] = fn(m.worker)
+		}(i, m)
+	}
+
+	wg.Wait()
+
+	return newGroupError(members, errs)
+}
@@ -2,8 +2,7 @@ package porter
 
 import (
 	"context"
-	"fmt"
-	"runtime"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -26,41 +25,148 @@ func WithMiddleware(middlewares ...MiddlewareFunc) Opt {
 	}
 }
 
-func JobTTLMiddleware(ttl time.Duration) MiddlewareFunc {
+// JobOverrun describes a job that didn't return within its TTL
+type JobOverrun struct {
+	RunningJob
+	TTL time.Duration
+	// Time the job took until it returned, zero if the job was abandoned
+	Duration time.Duration
+	// The job was left running in the background, so it doesn't occupy the jobs limit anymore
+	Abandoned bool
+}
+
+type ttlConfig struct {
+	softKill bool
+	grace    time.Duration
+}
+
+// TTLOpt configures JobTTLMiddleware
+type TTLOpt func(c *ttlConfig)
+
+// WithSoftKill makes JobTTLMiddleware wait for the job to return after its context is cancelled by the TTL,
+// so the job keeps occupying its slot of the jobs limit, but not longer than the grace period.
+// The job that didn't return within the grace period is abandoned. Non-positive grace means the grace period equal to the TTL
+func WithSoftKill(grace time.Duration) TTLOpt {
+	return func(c *ttlConfig) {
+		c.softKill = true
+		c.grace = grace
+	}
+}
+
+// JobTTLMiddleware cancels the job context after the TTL and returns context.DeadlineExceeded.
+// By default the job that didn't return in time is abandoned and keeps running in the background,
+// see WithSoftKill. The jobs that overran the TTL are reported by Subscriber.ListenJobOverrun
+func JobTTLMiddleware(ttl time.Duration, opts ...TTLOpt) MiddlewareFunc {
 	if ttl <= 0 {
 		return emptyMiddleware
 	}
 
+	config := newTTLConfig(opts)
+
 	return func(next JobFunc) JobFunc {
 		return func(state State) error {
-			result := make(chan error)
-			panicChan := make(chan interface{}, 1)
+			return runWithTTL(next, state, ttl, config)
+		}
+	}
+}
 
-			ctx, cancel := context.WithTimeout(state.Context(), ttl)
-			s := state.WithContext(ctx)
-			defer cancel()
+// JobDeadlineMiddleware is JobTTLMiddleware with the TTL calculated for every job by the budget function,
+// e.g. depending on the values added to the job context by the previous middlewares.
+// The TTL is capped by the limit, if the budget is not positive, the TTL is the limit. Zero limit means no cap
+func JobDeadlineMiddleware(budget func(State) time.Duration, limit time.Duration, opts ...TTLOpt) MiddlewareFunc {
+	config := newTTLConfig(opts)
 
-			go func() {
-				defer func() {
-					if p := recover(); p != nil {
-						panicChan <- p
-					}
-				}()
-				result <- next(s)
-			}()
+	return func(next JobFunc) JobFunc {
+		return func(state State) error {
+			ttl := time.Duration(0)
+			if budget != nil {
+				ttl = budget(state)
+			}
 
-			select {
-			case p := <-panicChan:
-				panic(p)
-			case err := <-result:
-				return err
-			case <-ctx.Done():
-				return ctx.Err()
+			if limit > 0 && (ttl <= 0 || ttl > limit) {
+				ttl = limit
 			}
+
+			if ttl <= 0 {
+				return next(state)
+			}
+
+			return runWithTTL(next, state, ttl, config)
 		}
 	}
 }
 
+func newTTLConfig(opts []TTLOpt) ttlConfig {
+	config := ttlConfig{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+
+	return config
+}
+
+// runWithTTL performs the job on its own goroutine and returns context.DeadlineExceeded after the TTL
+func runWithTTL(next JobFunc, state State, ttl time.Duration, config ttlConfig) error {
+	// the channels are buffered, so the abandoned job doesn't block when it returns
+	result := make(chan error, 1)
+	panicChan := make(chan interface{}, 1)
+
+	startedAt := time.Now()
+	ctx, cancel := context.WithTimeout(state.Context(), ttl)
+	s := state.WithContext(ctx)
+	defer cancel()
+
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				panicChan <- p
+			}
+		}()
+		result <- next(s)
+	}()
+
+	select {
+	case p := <-panicChan:
+		panic(p)
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+	}
+
+	err := ctx.Err()
+	overrun := JobOverrun{TTL: ttl, Abandoned: true}
+
+	if config.softKill {
+		grace := config.grace
+		if grace <= 0 {
+			grace = ttl
+		}
+
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+
+		select {
+		case p := <-panicChan:
+			panic(p)
+		case <-result:
+			overrun.Abandoned = false
+			overrun.Duration = time.Since(startedAt)
+		case <-timer.C:
+		}
+	}
+
+	// the cancellation of the worker is not an overrun
+	if errors.Is(err, context.DeadlineExceeded) {
+		if sl := slotFromContext(state.Context()); sl != nil {
+			sl.notifyOverrun(overrun)
+		}
+	}
+
+	return err
+}
+
 type jobIDContextKey struct{}
 
 var jobIDKey = jobIDContextKey{}
@@ -71,6 +177,10 @@ func JobIDMiddleware() MiddlewareFunc {
 		return func(state State) error {
 			id := uuid.New().String()
 
+			if sl := slotFromContext(state.Context()); sl != nil {
+				sl.setJobID(id)
+			}
+
 			return next(state.WithContext(context.WithValue(state.Context(), jobIDKey, id)))
 		}
 	}
@@ -89,16 +199,103 @@ func JobIDFromContext(ctx context.Context) string {
 	return ""
 }
 
-// RecoverMiddleware recovers panic and transforms it into an error return
-func RecoverMiddleware() MiddlewareFunc {
+type attemptContextKey struct{}
+
+var attemptKey = attemptContextKey{}
+
+// RetryMiddleware calls the job again up to the given number of retries if it returns a retryable error,
+// the retries are delayed by the backoff. If retryable is nil, every error is retried.
+// The errors of ClassIdle, ErrIdleJob and ErrCircuitOpen, are never retried and the retries stop when the job context is done
+func RetryMiddleware(retries int, backoff Backoff, retryable func(error) bool) MiddlewareFunc {
+	if retries <= 0 {
+		return emptyMiddleware
+	}
+
+	return func(next JobFunc) JobFunc {
+		return func(state State) error {
+			ctx := state.Context()
+			timeout := time.Duration(0)
+
+			for attempt := 1; ; attempt++ {
+				err := next(state.WithContext(context.WithValue(ctx, attemptKey, attempt)))
+				if err == nil || classifyError(nil, err).class == ClassIdle || attempt > retries {
+					return err
+				}
+
+				if retryable != nil && !retryable(err) {
+					return err
+				}
+
+				if backoff != nil {
+					timeout = backoff.Timeout(attempt, timeout)
+				}
+
+				// the error of the last attempt is more useful than the cancellation
+				if sleep(ctx, timeout) != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// AttemptFromState returns the number of the attempt starting from 1 if the job is called by RetryMiddleware
+func AttemptFromState(state State) int {
+	return AttemptFromContext(state.Context())
+}
+
+// AttemptFromContext returns the number of the attempt starting from 1 if the job is called by RetryMiddleware
+func AttemptFromContext(ctx context.Context) int {
+	if attempt, ok := ctx.Value(attemptKey).(int); ok {
+		return attempt
+	}
+	return 0
+}
+
+// sleep waits for the timeout unless the context is done before
+func sleep(ctx context.Context, timeout time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if timeout <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RecoverMiddleware recovers panic and transforms it into *PanicError
+func RecoverMiddleware(opts ...RecoverOpt) MiddlewareFunc {
+	config := recoverConfig{stackSize: defaultPanicStackSize}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+
 	return func(next JobFunc) JobFunc {
 		return func(state State) (err error) {
 			defer func() {
 				if r := recover(); r != nil {
-					const size = 64 << 10
-					buf := make([]byte, size)
-					buf = buf[:runtime.Stack(buf, false)]
-					err = fmt.Errorf("porter: panic %v\n%s", r, buf)
+					panicErr := capturePanic(r, config)
+					err = panicErr
+
+					if sl := slotFromContext(state.Context()); sl != nil {
+						sl.notifyPanic(err)
+					}
+
+					if config.report != nil {
+						config.report(state, panicErr)
+					}
 				}
 			}()
 
@@ -1,7 +1,13 @@
 package porter
 
 import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -33,3 +39,278 @@ func TestRecoverMiddleware(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "porter: panic test")
 }
+
+func TestRetryMiddleware(t *testing.T) {
+	errTest := errors.New("test error")
+	errFatal := errors.New("fatal error")
+
+	t.Run("Retries", func(t *testing.T) {
+		var attempts []int
+		var delays []time.Duration
+		fn := applyMiddleware(
+			func(s State) error {
+				attempts = append(attempts, AttemptFromState(s))
+				return errTest
+			},
+			RetryMiddleware(2, BackoffFunc(func(failures int, prev time.Duration) time.Duration {
+				delays = append(delays, prev)
+				return time.Duration(failures) * time.Millisecond
+			}), nil),
+		)
+
+		assert.Equal(t, errTest, fn(&state{}))
+		assert.Equal(t, []int{1, 2, 3}, attempts)
+		assert.Equal(t, []time.Duration{0, 1 * time.Millisecond}, delays)
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		calls := 0
+		fn := applyMiddleware(
+			func(s State) error {
+				calls++
+				if calls < 2 {
+					return errTest
+				}
+				return nil
+			},
+			RetryMiddleware(5, nil, nil),
+		)
+
+		assert.NoError(t, fn(&state{}))
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("NotRetryable", func(t *testing.T) {
+		for _, err := range []error{errFatal, ErrIdleJob, ErrCircuitOpen} {
+			calls := 0
+			fn := applyMiddleware(
+				func(s State) error {
+					calls++
+					return err
+				},
+				RetryMiddleware(5, nil, func(err error) bool {
+					return !errors.Is(err, errFatal)
+				}),
+			)
+
+			assert.Equal(t, err, fn(&state{}))
+			assert.Equal(t, 1, calls)
+		}
+	})
+
+	t.Run("ContextDone", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		calls := 0
+		fn := applyMiddleware(
+			func(s State) error {
+				calls++
+				cancel()
+				return errTest
+			},
+			RetryMiddleware(5, ConstantBackoff(time.Minute), nil),
+		)
+
+		assert.Equal(t, errTest, fn(&state{ctx: ctx}))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("WithoutMiddleware", func(t *testing.T) {
+		assert.Equal(t, 0, AttemptFromState(&state{}))
+	})
+}
+
+func TestJobTTLMiddleware(t *testing.T) {
+	slowJob := func(duration time.Duration, returned chan<- struct{}) JobFunc {
+		return func(s State) error {
+			// the job ignores the cancellation
+			time.Sleep(duration)
+			close(returned)
+
+			return nil
+		}
+	}
+
+	t.Run("Abandon", func(t *testing.T) {
+		returned := make(chan struct{})
+
+		fn := applyMiddleware(slowJob(50*time.Millisecond, returned), JobTTLMiddleware(10*time.Millisecond))
+
+		startedAt := time.Now()
+		assert.Equal(t, context.DeadlineExceeded, fn(&state{}))
+		assert.True(t, time.Since(startedAt) < 40*time.Millisecond)
+
+		// the abandoned job doesn't leak when it returns
+		<-returned
+		assert.Eventually(t, func() bool {
+			buf := make([]byte, 1<<20)
+			buf = buf[:runtime.Stack(buf, true)]
+
+			return !strings.Contains(string(buf), "porter.runWithTTL")
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("SoftKill", func(t *testing.T) {
+		returned := make(chan struct{})
+		fn := applyMiddleware(
+			slowJob(50*time.Millisecond, returned),
+			JobTTLMiddleware(10*time.Millisecond, WithSoftKill(time.Second)),
+		)
+
+		assert.Equal(t, context.DeadlineExceeded, fn(&state{}))
+
+		select {
+		case <-returned:
+		default:
+			t.Fatal("the middleware must wait for the job to return")
+		}
+	})
+
+	t.Run("SoftKillGrace", func(t *testing.T) {
+		returned := make(chan struct{})
+		fn := applyMiddleware(
+			slowJob(200*time.Millisecond, returned),
+			JobTTLMiddleware(10*time.Millisecond, WithSoftKill(20*time.Millisecond)),
+		)
+
+		startedAt := time.Now()
+		assert.Equal(t, context.DeadlineExceeded, fn(&state{}))
+		assert.True(t, time.Since(startedAt) < 150*time.Millisecond)
+		<-returned
+	})
+
+	t.Run("SoftKillDefaultGrace", func(t *testing.T) {
+		returned := make(chan struct{})
+		fn := applyMiddleware(
+			slowJob(70*time.Millisecond, returned),
+			JobTTLMiddleware(50*time.Millisecond, WithSoftKill(0)),
+		)
+
+		// the grace period is the TTL
+		assert.Equal(t, context.DeadlineExceeded, fn(&state{}))
+
+		select {
+		case <-returned:
+		default:
+			t.Fatal("the middleware must wait for the job to return")
+		}
+	})
+
+	t.Run("Overrun", func(t *testing.T) {
+		overruns := make(chan JobOverrun, 10)
+		var running, maxRunning int64
+
+		w := NewWorker(
+			func(s State) error {
+				n := atomic.AddInt64(&running, 1)
+				defer atomic.AddInt64(&running, -1)
+
+				for {
+					max := atomic.LoadInt64(&maxRunning)
+					if n <= max || atomic.CompareAndSwapInt64(&maxRunning, max, n) {
+						break
+					}
+				}
+
+				time.Sleep(30 * time.Millisecond)
+				return nil
+			},
+			WithMiddleware(JobTTLMiddleware(10*time.Millisecond, WithSoftKill(time.Second))),
+			WithSubscriber(func(s Subscriber) {
+				s.ListenJobOverrun(func(overrun JobOverrun) {
+					overruns <- overrun
+				})
+			}),
+		)
+
+		assert.NoError(t, w.Run())
+
+		overrun := <-overruns
+		assert.False(t, overrun.Abandoned)
+		assert.Equal(t, 10*time.Millisecond, overrun.TTL)
+		assert.True(t, overrun.Duration >= 30*time.Millisecond)
+
+		<-overruns
+		assert.NoError(t, w.Shutdown(context.Background()))
+
+		// the overran jobs keep the limit
+		assert.Equal(t, int64(1), atomic.LoadInt64(&maxRunning))
+		assert.True(t, w.Stats().Overran >= 2)
+	})
+}
+
+func TestJobDeadlineMiddleware(t *testing.T) {
+	type sizeKey struct{}
+
+	deadline := func(s State) time.Duration {
+		d, ok := s.Context().Deadline()
+		assert.True(t, ok)
+		return time.Until(d)
+	}
+
+	budget := func(s State) time.Duration {
+		size, _ := s.Context().Value(sizeKey{}).(int)
+		return time.Duration(size) * time.Second
+	}
+
+	run := func(size int, limit time.Duration) time.Duration {
+		var remaining time.Duration
+		fn := applyMiddleware(
+			func(s State) error {
+				remaining = deadline(s)
+				return nil
+			},
+			func(next JobFunc) JobFunc {
+				return func(s State) error {
+					return next(s.WithContext(context.WithValue(s.Context(), sizeKey{}, size)))
+				}
+			},
+			JobDeadlineMiddleware(budget, limit),
+		)
+
+		assert.NoError(t, fn(&state{}))
+
+		return remaining
+	}
+
+	t.Run("Budget", func(t *testing.T) {
+		remaining := run(2, time.Minute)
+		assert.True(t, remaining > time.Second && remaining <= 2*time.Second)
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		remaining := run(120, time.Minute)
+		assert.True(t, remaining > 59*time.Second && remaining <= time.Minute)
+
+		// the job without the budget gets the limit
+		remaining = run(0, time.Minute)
+		assert.True(t, remaining > 59*time.Second && remaining <= time.Minute)
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		fn := applyMiddleware(
+			func(s State) error {
+				<-s.Context().Done()
+				return nil
+			},
+			JobDeadlineMiddleware(func(State) time.Duration {
+				return 10 * time.Millisecond
+			}, time.Minute),
+		)
+
+		assert.Equal(t, context.DeadlineExceeded, fn(&state{}))
+	})
+
+	t.Run("NoLimit", func(t *testing.T) {
+		fn := applyMiddleware(
+			func(s State) error {
+				_, ok := s.Context().Deadline()
+				assert.False(t, ok)
+				return nil
+			},
+			JobDeadlineMiddleware(nil, 0),
+		)
+
+		assert.NoError(t, fn(&state{}))
+	})
+}
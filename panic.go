@@ -0,0 +1,126 @@
+package porter
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+const (
+	defaultPanicStackSize = 64 << 10
+	maxPanicFrames        = 64
+)
+
+// PanicError is the error of the job that panicked
+type PanicError struct {
+	// The value passed to panic
+	Value interface{}
+	// Stack frames of the panicking goroutine starting from the function that panicked
+	Frames []runtime.Frame
+	// Stack dump in the format of runtime.Stack, empty if the capture is disabled by WithStackSize
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	if len(e.Stack) == 0 {
+		return fmt.Sprintf("porter: panic %v", e.Value)
+	}
+
+	return fmt.Sprintf("porter: panic %v\n%s", e.Value, e.Stack)
+}
+
+// Unwrap returns the panic value if it is an error, e.g. a runtime.Error
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+type recoverConfig struct {
+	stackSize     int
+	allGoroutines bool
+	report        func(State, *PanicError)
+}
+
+// RecoverOpt configures RecoverMiddleware
+type RecoverOpt func(c *recoverConfig)
+
+// WithStackSize limits the stack dump of the panic, 64KiB by default, zero disables the dump.
+// The frames of PanicError are captured anyway
+func WithStackSize(size int) RecoverOpt {
+	return func(c *recoverConfig) {
+		if size >= 0 {
+			c.stackSize = size
+		}
+	}
+}
+
+// WithAllGoroutines adds the stacks of all the goroutines to the dump, it stops the world while capturing
+func WithAllGoroutines() RecoverOpt {
+	return func(c *recoverConfig) {
+		c.allGoroutines = true
+	}
+}
+
+// WithPanicReporter calls the function for every recovered panic, e.g. to send it to an error tracker
+func WithPanicReporter(report func(State, *PanicError)) RecoverOpt {
+	return func(c *recoverConfig) {
+		c.report = report
+	}
+}
+
+// newPanicError captures the panic with the default stack size, it is used outside of RecoverMiddleware,
+// e.g. for the panics that crash the worker and the panics of the event handlers
+func newPanicError(r interface{}) *PanicError {
+	return capturePanic(r, recoverConfig{stackSize: defaultPanicStackSize})
+}
+
+// capturePanic must be called by the recovering goroutine to capture its stack
+func capturePanic(r interface{}, config recoverConfig) *PanicError {
+	err := &PanicError{
+		Value:  r,
+		Frames: panicFrames(),
+	}
+
+	if config.stackSize > 0 {
+		buf := make([]byte, config.stackSize)
+		err.Stack = buf[:runtime.Stack(buf, config.allGoroutines)]
+	}
+
+	return err
+}
+
+// panicFrames returns the frames of the current goroutine below the panic
+func panicFrames() []runtime.Frame {
+	pcs := make([]uintptr, maxPanicFrames)
+	pcs = pcs[:runtime.Callers(1, pcs)]
+
+	var frames []runtime.Frame
+	it := runtime.CallersFrames(pcs)
+	for {
+		frame, more := it.Next()
+		frames = append(frames, frame)
+
+		if !more {
+			break
+		}
+	}
+
+	// the frames of the recovery are above runtime.gopanic
+	for i, frame := range frames {
+		if frame.Function == "runtime.gopanic" {
+			frames = frames[i+1:]
+			break
+		}
+	}
+
+	// the runtime errors, e.g. nil pointer dereference, are raised by the runtime functions
+	for len(frames) > 0 && isPanicFrame(frames[0].Function) {
+		frames = frames[1:]
+	}
+
+	return frames
+}
+
+func isPanicFrame(function string) bool {
+	return strings.HasPrefix(function, "runtime.panic") || function == "runtime.sigpanic"
+}
@@ -0,0 +1,71 @@
+package porter
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func panickingJob(s State) error {
+	panic("test panic")
+}
+
+func TestPanicError(t *testing.T) {
+	t.Run("Value", func(t *testing.T) {
+		err := applyMiddleware(panickingJob, RecoverMiddleware())(&state{})
+
+		var panicErr *PanicError
+		if assert.True(t, errors.As(err, &panicErr)) {
+			assert.Equal(t, "test panic", panicErr.Value)
+			assert.NotEmpty(t, panicErr.Stack)
+			assert.True(t, strings.HasPrefix(err.Error(), "porter: panic test panic\n"))
+
+			if assert.NotEmpty(t, panicErr.Frames) {
+				assert.True(t, strings.HasSuffix(panicErr.Frames[0].Function, ".panickingJob"))
+			}
+		}
+	})
+
+	t.Run("RuntimeError", func(t *testing.T) {
+		err := applyMiddleware(
+			func(s State) error {
+				var m map[string]int
+				m["test"] = 1
+				return nil
+			},
+			RecoverMiddleware(),
+		)(&state{})
+
+		var runtimeErr runtime.Error
+		assert.True(t, errors.As(err, &runtimeErr))
+	})
+
+	t.Run("StackSize", func(t *testing.T) {
+		err := applyMiddleware(panickingJob, RecoverMiddleware(WithStackSize(0)))(&state{})
+		assert.EqualError(t, err, "porter: panic test panic")
+
+		err = applyMiddleware(panickingJob, RecoverMiddleware(WithStackSize(100)))(&state{})
+		assert.Len(t, err.(*PanicError).Stack, 100)
+	})
+
+	t.Run("AllGoroutines", func(t *testing.T) {
+		err := applyMiddleware(panickingJob, RecoverMiddleware(WithAllGoroutines()))(&state{})
+		assert.True(t, bytes.Count(err.(*PanicError).Stack, []byte("goroutine ")) > 1)
+	})
+
+	t.Run("Reporter", func(t *testing.T) {
+		var reported *PanicError
+		s := &state{}
+
+		err := applyMiddleware(panickingJob, RecoverMiddleware(WithPanicReporter(func(state State, err *PanicError) {
+			assert.Equal(t, s, state)
+			reported = err
+		})))(s)
+
+		assert.Equal(t, err, reported)
+	})
+}
@@ -0,0 +1,592 @@
+package porter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+type slotContextKey struct{}
+
+var slotKey = slotContextKey{}
+
+// pool runs the jobs of a worker on a set of long-lived executors
+type pool struct {
+	job    JobFunc
+	config workerConfig
+	events *Dispatcher
+	// Counters of the worker jobs
+	counters *counters
+	// Parent context of every job, it is cancelled when the shutdown starts or after the grace period
+	ctx    context.Context
+	cancel context.CancelFunc
+	// While the channel is open, the executors will start new jobs, it is closed under mu
+	closed chan struct{}
+	// The channel is closed when all the executors have exited
+	done chan struct{}
+	// Running executors
+	wg sync.WaitGroup
+	// Adjusts the limit if the adaptive concurrency is enabled
+	adaptive *adaptiveLimit
+
+	// Guards the executors, the pause state and the number of jobs in progress
+	mu sync.Mutex
+	// Every executor performs jobs one by one, so the number of executors is the jobs limit
+	limit int
+	// Executors are started after the run delay
+	started bool
+	// Slots of the executors, the executor of the slot exits when its index exceeds the limit
+	slots    []*slot
+	inflight int
+	// The highest number of jobs in progress since the last adjustment of the adaptive limit
+	peak int
+	// While the worker is paused, the channel is not nil, it is closed on resume
+	resumed chan struct{}
+	// The channel is closed when the paused worker has no jobs in progress
+	drained chan struct{}
+	// The reason why the pool stopped by itself
+	err error
+}
+
+func newPool(
+	parent context.Context,
+	fn JobFunc,
+	config workerConfig,
+	events *Dispatcher,
+	counters *counters,
+	closed chan struct{},
+) *pool {
+	ctx, cancel := context.WithCancel(valueContext{parent})
+
+	p := &pool{
+		job:      applyMiddleware(fn, config.middlewares...),
+		config:   config,
+		events:   events,
+		counters: counters,
+		ctx:      ctx,
+		cancel:   cancel,
+		closed:   closed,
+		done:     make(chan struct{}),
+		limit:    config.jobsLimit,
+	}
+
+	if config.adaptive != nil {
+		p.adaptive = newAdaptiveLimit(*config.adaptive)
+		p.limit = p.adaptive.clamp(p.limit)
+
+		go p.adapt()
+	}
+
+	// without the delay the executors are started right away, so the worker is running when Run returns
+	if config.delay <= 0 {
+		p.start()
+	}
+
+	go p.cancelOnClose()
+	go p.run()
+
+	return p
+}
+
+func (p *pool) run() {
+	defer close(p.done)
+	defer p.cancel()
+
+	if p.config.delay > 0 {
+		select {
+		case <-p.closed:
+			return
+		case <-time.After(p.config.delay):
+		}
+
+		p.start()
+	}
+
+	p.wg.Wait()
+}
+
+// start starts the executors unless the pool is closed
+func (p *pool) start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.isClosed() {
+		p.started = true
+		p.spawn()
+	}
+}
+
+// close stops starting new jobs
+func (p *pool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.isClosed() {
+		close(p.closed)
+	}
+}
+
+// crash stops the pool because of the job panic
+func (p *pool) crash(err error) {
+	p.mu.Lock()
+	if p.err == nil {
+		p.err = err
+	}
+	p.mu.Unlock()
+
+	p.close()
+}
+
+// Err returns the reason why the pool stopped by itself
+func (p *pool) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.err
+}
+
+// isClosed must be called under mu
+func (p *pool) isClosed() bool {
+	select {
+	case <-p.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// resize changes the number of executors, the excess executors exit after their current jobs.
+// The limit is kept within the bounds of the adaptive concurrency if it is enabled
+func (p *pool) resize(limit int) {
+	if p.adaptive != nil {
+		limit = p.adaptive.clamp(limit)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.limit = limit
+
+	if p.started && !p.isClosed() {
+		p.spawn()
+	}
+}
+
+// spawn starts the executors up to the limit, it must be called under mu.
+// The closed channel is closed under mu too, so the executors can't be started after the pool is done
+func (p *pool) spawn() {
+	for i := 0; i < p.limit; i++ {
+		if i == len(p.slots) {
+			p.slots = append(p.slots, &slot{pool: p, index: i})
+		}
+
+		sl := p.slots[i]
+		if sl.alive {
+			continue
+		}
+
+		sl.alive = true
+		p.wg.Add(1)
+
+		go p.execute(sl)
+	}
+}
+
+func (p *pool) execute(sl *slot) {
+	defer p.exit(sl)
+	p.runExecutor(sl)
+}
+
+// exit releases the slot of the executor. The limit may have grown after the executor exceeded it
+// and before the slot was released, so the executor is restarted if the slot is within the limit again
+func (p *pool) exit(sl *slot) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if sl.index < p.limit && !p.isClosed() {
+		go p.execute(sl)
+		return
+	}
+
+	sl.alive = false
+	p.wg.Done()
+}
+
+// adapt adjusts the limit by the results of the jobs at the end of every window
+func (p *pool) adapt() {
+	ticker := time.NewTicker(p.adaptive.config.Window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+
+		p.mu.Lock()
+		limit, saturated := p.limit, p.peak >= p.limit
+		p.peak = p.inflight
+		p.mu.Unlock()
+
+		if next := p.adaptive.next(limit, saturated); next != limit {
+			p.resize(next)
+		}
+	}
+}
+
+func (p *pool) cancelOnClose() {
+	select {
+	case <-p.done:
+		return
+	case <-p.closed:
+	}
+
+	if p.config.gracePeriod > 0 {
+		select {
+		case <-p.done:
+		case <-time.After(p.config.gracePeriod):
+		}
+	}
+
+	p.cancel()
+}
+
+func (p *pool) runExecutor(sl *slot) {
+	// The state is immutable, so it can be shared between the jobs of the executor
+	s := &state{ctx: context.WithValue(p.ctx, slotKey, sl)}
+	streak := &failureStreak{}
+
+	for {
+		select {
+		default:
+		case <-p.closed:
+			return
+		}
+
+		if !p.begin(sl) {
+			return
+		}
+
+		job := sl.start()
+		p.counters.start()
+		p.events.OnJobStart(job)
+
+		err := p.call(sl, s)
+		job = sl.finish()
+		duration := time.Since(job.StartedAt)
+		p.release()
+
+		class := classifyError(p.config.errorClasses, err)
+		p.counters.finish(class.class)
+
+		failed := class.class != ClassSuccess && class.class != ClassIdle
+		if p.adaptive != nil {
+			p.adaptive.observe(duration, failed)
+		}
+
+		if failed {
+			p.events.OnJobError(class.class, err)
+		}
+
+		if errors.Is(err, context.DeadlineExceeded) {
+			p.events.OnJobTimeout(job, err)
+		}
+
+		p.events.OnJobFinish(JobResult{RunningJob: job, Duration: duration, Class: class.class, Err: err})
+
+		if timeout := getTimeout(p.config, err, streak); timeout > 0 {
+			select {
+			case <-time.After(timeout):
+			case <-p.closed:
+				return
+			}
+		}
+	}
+}
+
+// call performs the job, the panic of the job stops the pool if the worker exits on panic,
+// otherwise the panic is emitted and propagated
+func (p *pool) call(sl *slot, s State) (err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		err = newPanicError(r)
+		sl.notifyPanic(err)
+
+		if !p.config.exitOnPanic {
+			panic(r)
+		}
+
+		p.crash(err)
+	}()
+
+	return p.job(s)
+}
+
+// waitToken blocks until the rate limiter allows to start a job, it returns false if the worker is closed
+func (p *pool) waitToken() bool {
+	if p.config.rateLimiter == nil {
+		return true
+	}
+
+	for {
+		delay := p.config.rateLimiter.take()
+		if delay == 0 {
+			return true
+		}
+
+		select {
+		case <-p.closed:
+			return false
+		case <-time.After(delay):
+		}
+	}
+}
+
+// begin takes a token of the rate limiter and a place within the jobs limit, it blocks while the worker is paused.
+// The token is given back if the job can't be started, so the paused or excess executors don't hold the shared budget.
+// It returns false if the worker is closed or the executor exceeds the limit
+func (p *pool) begin(sl *slot) bool {
+	for {
+		if !p.waitToken() {
+			return false
+		}
+
+		resumed, ok := p.acquire(sl)
+		if ok {
+			return true
+		}
+
+		if p.config.rateLimiter != nil {
+			p.config.rateLimiter.giveBack()
+		}
+
+		if resumed == nil {
+			return false
+		}
+
+		select {
+		case <-resumed:
+		case <-p.closed:
+			return false
+		}
+	}
+}
+
+// acquire takes a place within the jobs limit, it fails if the executor exceeds the limit
+// or the worker is paused, in the latter case the channel closed on resume is returned
+func (p *pool) acquire(sl *slot) (<-chan struct{}, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if sl.index >= p.limit {
+		return nil, false
+	}
+
+	if p.resumed != nil {
+		return p.resumed, false
+	}
+
+	p.inflight++
+	if p.inflight > p.peak {
+		p.peak = p.inflight
+	}
+
+	return nil, true
+}
+
+func (p *pool) release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.inflight--
+	if p.inflight == 0 && p.drained != nil {
+		close(p.drained)
+		p.drained = nil
+	}
+}
+
+// pause stops starting new jobs and waits for the jobs in progress
+func (p *pool) pause(ctx context.Context) error {
+	p.mu.Lock()
+	if p.resumed == nil {
+		p.resumed = make(chan struct{})
+	}
+
+	if p.inflight == 0 {
+		p.mu.Unlock()
+		return nil
+	}
+
+	if p.drained == nil {
+		p.drained = make(chan struct{})
+	}
+	drained := p.drained
+	p.mu.Unlock()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// resume continues starting new jobs, it returns false if the worker is not paused
+func (p *pool) resume() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.resumed == nil {
+		return false
+	}
+
+	close(p.resumed)
+	p.resumed = nil
+
+	return true
+}
+
+// status returns the stage of the pool lifecycle
+func (p *pool) status() Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-p.done:
+		return StatusStopped
+	default:
+	}
+
+	switch {
+	case p.isClosed():
+		return StatusStopping
+	case !p.started:
+		return StatusDelayed
+	case p.resumed != nil:
+		return StatusPaused
+	default:
+		return StatusRunning
+	}
+}
+
+func (p *pool) inFlight() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.inflight
+}
+
+// running returns the jobs that are currently in progress
+func (p *pool) running() []RunningJob {
+	p.mu.Lock()
+	slots := p.slots
+	p.mu.Unlock()
+
+	var jobs []RunningJob
+	for _, sl := range slots {
+		if job, ok := sl.job(); ok {
+			jobs = append(jobs, job)
+		}
+	}
+
+	return jobs
+}
+
+// slot keeps track of the job performed by an executor
+type slot struct {
+	pool  *pool
+	index int
+	// The executor of the slot is running, guarded by the mutex of the pool
+	alive bool
+
+	mu        sync.Mutex
+	running   bool
+	startedAt time.Time
+	jobID     string
+}
+
+func slotFromContext(ctx context.Context) *slot {
+	sl, _ := ctx.Value(slotKey).(*slot)
+	return sl
+}
+
+func (sl *slot) start() RunningJob {
+	now := time.Now()
+
+	sl.mu.Lock()
+	sl.running = true
+	sl.startedAt = now
+	sl.jobID = ""
+	sl.mu.Unlock()
+
+	return RunningJob{Executor: sl.index, StartedAt: now}
+}
+
+// finish returns the finished job with the identifier set during the job
+func (sl *slot) finish() RunningJob {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	sl.running = false
+
+	return RunningJob{
+		ID:        sl.jobID,
+		Executor:  sl.index,
+		StartedAt: sl.startedAt,
+	}
+}
+
+// notifyOverrun counts and emits the overrun of the current job
+func (sl *slot) notifyOverrun(overrun JobOverrun) {
+	overrun.RunningJob, _ = sl.job()
+
+	sl.pool.counters.overrun()
+	sl.pool.events.OnJobOverrun(overrun)
+}
+
+// notifyPanic emits the panic of the current job
+func (sl *slot) notifyPanic(err error) {
+	job, _ := sl.job()
+	sl.pool.events.OnJobPanic(job, err)
+}
+
+func (sl *slot) setJobID(id string) {
+	sl.mu.Lock()
+	sl.jobID = id
+	sl.mu.Unlock()
+}
+
+func (sl *slot) job() (RunningJob, bool) {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	return RunningJob{
+		ID:        sl.jobID,
+		Executor:  sl.index,
+		StartedAt: sl.startedAt,
+	}, sl.running
+}
+
+// valueContext keeps the values of the parent context but not its cancellation,
+// the worker reacts to the cancellation of the parent by shutting down
+type valueContext struct {
+	context.Context
+}
+
+func (valueContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (valueContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (valueContext) Err() error {
+	return nil
+}
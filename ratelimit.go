@@ -0,0 +1,103 @@
+package porter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket that limits the rate of job starts,
+// it can be shared by several workers to draw from the same budget
+type RateLimiter struct {
+	mu sync.Mutex
+	// Tokens per second
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter allows rate jobs per second with bursts of at most burst jobs
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &RateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// WithRateLimit limits the rate of job starts of the worker, zero or negative rate means no limit
+func WithRateLimit(rate float64, burst int) Opt {
+	return func(w *worker) {
+		if rate > 0 {
+			w.config.rateLimiter = NewRateLimiter(rate, burst)
+		}
+	}
+}
+
+// WithRateLimiter limits the rate of job starts by the shared limiter
+func WithRateLimiter(limiter *RateLimiter) Opt {
+	return func(w *worker) {
+		w.config.rateLimiter = limiter
+	}
+}
+
+// Wait blocks until a token is available or the context is done
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		delay := l.take()
+		if delay == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// take takes a token if it is available, otherwise returns the time until the next token
+func (l *RateLimiter) take() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate <= 0 {
+		return 0
+	}
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+// giveBack returns the token that was taken but not used
+func (l *RateLimiter) giveBack() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate <= 0 {
+		return
+	}
+
+	l.tokens++
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+}
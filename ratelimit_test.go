@@ -0,0 +1,91 @@
+package porter
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	t.Run("Burst", func(t *testing.T) {
+		l := NewRateLimiter(1, 2)
+
+		assert.Equal(t, time.Duration(0), l.take())
+		assert.Equal(t, time.Duration(0), l.take())
+		assert.Greater(t, int64(l.take()), int64(900*time.Millisecond))
+	})
+
+	t.Run("Wait", func(t *testing.T) {
+		l := NewRateLimiter(1, 1)
+		assert.NoError(t, l.Wait(context.Background()))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		assert.Equal(t, context.DeadlineExceeded, l.Wait(ctx))
+	})
+}
+
+func TestWorker_RateLimit(t *testing.T) {
+	var jobs int64
+	job := func(state State) error {
+		atomic.AddInt64(&jobs, 1)
+		return nil
+	}
+
+	// two workers with four executors each share 20 jobs per second
+	limiter := NewRateLimiter(20, 1)
+	first := NewWorker(job, WithJobsLimit(4), WithRateLimiter(limiter))
+	second := NewWorker(job, WithJobsLimit(4), WithRateLimiter(limiter))
+	g := NewWorkerGroup(first, second)
+
+	assert.NoError(t, g.Run())
+	time.Sleep(500 * time.Millisecond)
+	assert.NoError(t, g.Shutdown(context.Background()))
+
+	n := atomic.LoadInt64(&jobs)
+	assert.GreaterOrEqual(t, n, int64(8))
+	assert.LessOrEqual(t, n, int64(12))
+}
+
+func TestWorker_RateLimitPaused(t *testing.T) {
+	release := make(chan struct{})
+	limiter := NewRateLimiter(0.01, 8)
+
+	w := NewWorker(
+		func(state State) error {
+			<-release
+			return nil
+		},
+		WithJobsLimit(4),
+		WithRateLimiter(limiter),
+	)
+
+	assert.NoError(t, w.Run())
+	assert.Eventually(t, func() bool {
+		return w.Stats().InFlight == 4
+	}, time.Second, 10*time.Millisecond)
+
+	paused := make(chan error, 1)
+	go func() {
+		paused <- w.Pause(context.Background())
+	}()
+	assert.Eventually(t, func() bool {
+		return w.Status() == StatusPaused
+	}, time.Second, 10*time.Millisecond)
+	close(release)
+	assert.NoError(t, <-paused)
+
+	// the paused executors give the tokens back, so the budget is left to the other workers
+	assert.Eventually(t, func() bool {
+		limiter.mu.Lock()
+		defer limiter.mu.Unlock()
+
+		return limiter.tokens >= 4
+	}, time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, w.Shutdown(context.Background()))
+}
@@ -0,0 +1,126 @@
+package porter
+
+import "sync/atomic"
+
+// Status is the stage of the worker lifecycle
+type Status int
+
+const (
+	// StatusIdle means the worker has never been run
+	StatusIdle Status = iota
+	// StatusDelayed means the worker is waiting for the run delay
+	StatusDelayed
+	// StatusRunning means the worker is starting new jobs
+	StatusRunning
+	// StatusPaused means the worker is paused and does not start new jobs
+	StatusPaused
+	// StatusStopping means the worker is waiting for the jobs in progress to stop
+	StatusStopping
+	// StatusStopped means the worker loop has exited
+	StatusStopped
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusIdle:
+		return "idle"
+	case StatusDelayed:
+		return "delayed"
+	case StatusRunning:
+		return "running"
+	case StatusPaused:
+		return "paused"
+	case StatusStopping:
+		return "stopping"
+	case StatusStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
+
+// Stats are the counters of the worker jobs, the counters are kept between the runs
+type Stats struct {
+	// Jobs in progress
+	InFlight int
+	// Jobs started by the worker
+	Started int64
+	// Jobs that returned nil
+	Succeeded int64
+	// Jobs that returned an error of a class other than ClassIdle
+	Failed int64
+	// Jobs that returned ErrIdleJob or were short-circuited by CircuitBreakerMiddleware
+	Idle int64
+	// Jobs that didn't return within the TTL of JobTTLMiddleware
+	Overran int64
+}
+
+// add sums the counters of the workers
+func (s Stats) add(other Stats) Stats {
+	s.InFlight += other.InFlight
+	s.Started += other.Started
+	s.Succeeded += other.Succeeded
+	s.Failed += other.Failed
+	s.Idle += other.Idle
+	s.Overran += other.Overran
+
+	return s
+}
+
+// counters are updated by the executors without locking
+type counters struct {
+	started   int64
+	succeeded int64
+	failed    int64
+	idle      int64
+	overran   int64
+}
+
+func (c *counters) start() {
+	atomic.AddInt64(&c.started, 1)
+}
+
+func (c *counters) finish(class ErrorClass) {
+	switch class {
+	case ClassSuccess:
+		atomic.AddInt64(&c.succeeded, 1)
+	case ClassIdle:
+		atomic.AddInt64(&c.idle, 1)
+	default:
+		atomic.AddInt64(&c.failed, 1)
+	}
+}
+
+func (c *counters) overrun() {
+	atomic.AddInt64(&c.overran, 1)
+}
+
+func (c *counters) stats(inflight int) Stats {
+	return Stats{
+		InFlight:  inflight,
+		Started:   atomic.LoadInt64(&c.started),
+		Succeeded: atomic.LoadInt64(&c.succeeded),
+		Failed:    atomic.LoadInt64(&c.failed),
+		Idle:      atomic.LoadInt64(&c.idle),
+		Overran:   atomic.LoadInt64(&c.overran),
+	}
+}
+
+// groupStatus aggregates the statuses of the members: the group is stopping if any member is stopping,
+// otherwise it has the status of its most active member
+func groupStatus(statuses []Status) Status {
+	if len(statuses) == 0 {
+		return StatusIdle
+	}
+
+	precedence := []Status{StatusStopping, StatusRunning, StatusDelayed, StatusPaused, StatusStopped}
+	for _, status := range precedence {
+		for _, s := range statuses {
+			if s == status {
+				return status
+			}
+		}
+	}
+
+	return StatusIdle
+}
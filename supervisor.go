@@ -0,0 +1,276 @@
+package porter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrTooManyRestarts = errors.New("too many restarts")
+
+const (
+	defaultMaxRestarts   = 1
+	defaultRestartWindow = 5 * time.Second
+)
+
+// RestartStrategy defines which workers of the supervised group are restarted when one of them exits
+type RestartStrategy int
+
+const (
+	// OneForOne restarts only the exited worker
+	OneForOne RestartStrategy = iota
+	// OneForAll restarts all the workers of the group
+	OneForAll
+	// RestForOne restarts the exited worker and the workers started after it
+	RestForOne
+)
+
+// Restart describes a restart of the worker by the supervised group
+type Restart struct {
+	// Position of the restarted worker in the start order of the group
+	Index int
+	// Name of the worker added by WithMember
+	Name string
+	// The reason why the worker exited, see Worker.Err
+	Reason error
+	// The error of the restart, the failed restart is retried
+	Err error
+}
+
+type supervisorConfig struct {
+	strategy    RestartStrategy
+	maxRestarts int
+	window      time.Duration
+}
+
+// WithSupervisor restarts the workers that exited by themselves, e.g. because of a panic with WithExitOnPanic,
+// or were shut down bypassing the group. If there are more than maxRestarts restarts within the window,
+// the group is shut down and Err returns ErrTooManyRestarts.
+// Non-positive maxRestarts and window are replaced by 1 restart within 5s,
+// otherwise the restarts would never be counted and a failing worker would be restarted endlessly
+func WithSupervisor(strategy RestartStrategy, maxRestarts int, window time.Duration) GroupOpt {
+	return func(g *workerGroup) {
+		if maxRestarts <= 0 {
+			maxRestarts = defaultMaxRestarts
+		}
+		if window <= 0 {
+			window = defaultRestartWindow
+		}
+
+		g.supervisor = &supervisorConfig{
+			strategy:    strategy,
+			maxRestarts: maxRestarts,
+			window:      window,
+		}
+	}
+}
+
+// exit is sent by the monitor when the run of the worker is over
+type exit struct {
+	member *member
+	done   <-chan struct{}
+}
+
+type supervisor struct {
+	group  *workerGroup
+	config supervisorConfig
+	ctx    context.Context
+	// While the channel is open, the supervisor restarts the exited workers
+	stopping chan struct{}
+	exits    chan exit
+	// The channel is closed when the supervisor returns to release the monitors
+	quit chan struct{}
+	// Time of the restarts within the window
+	restarts []time.Time
+
+	mu sync.Mutex
+	// Done channels of the current runs of the supervised members
+	current map[*member]<-chan struct{}
+}
+
+func newSupervisor(g *workerGroup, ctx context.Context, members []*member) *supervisor {
+	s := &supervisor{
+		group:    g,
+		config:   *g.supervisor,
+		ctx:      ctx,
+		stopping: make(chan struct{}),
+		exits:    make(chan exit),
+		quit:     make(chan struct{}),
+		current:  make(map[*member]<-chan struct{}, len(members)),
+	}
+
+	for _, m := range members {
+		s.monitor(m)
+	}
+
+	return s
+}
+
+// stop prevents the supervisor from restarting the workers, it must be called under changeMu of the group
+func (s *supervisor) stop() {
+	select {
+	case <-s.stopping:
+	default:
+		close(s.stopping)
+	}
+}
+
+// monitor sends the exit of the current run of the member
+func (s *supervisor) monitor(m *member) {
+	done := m.worker.Done()
+
+	s.mu.Lock()
+	s.current[m] = done
+	s.mu.Unlock()
+
+	go func() {
+		select {
+		case <-s.quit:
+			return
+		case <-done:
+		}
+
+		select {
+		case <-s.quit:
+		case s.exits <- exit{member: m, done: done}:
+		}
+	}()
+}
+
+// forget stops supervising the member removed from the group
+func (s *supervisor) forget(m *member) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.current, m)
+}
+
+// isCurrent reports whether the exit belongs to the current run of the supervised member
+func (s *supervisor) isCurrent(e exit) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	done, ok := s.current[e.member]
+
+	return ok && done == e.done
+}
+
+// run restarts the exited workers until the group is stopping or the context is cancelled,
+// it returns an error if the restart intensity is exceeded
+func (s *supervisor) run() error {
+	defer close(s.quit)
+
+	for {
+		select {
+		case <-s.stopping:
+			return nil
+		case <-s.ctx.Done():
+			return nil
+		case e := <-s.exits:
+			// the worker has been restarted or removed since the exit
+			if !s.isCurrent(e) {
+				continue
+			}
+
+			if err := s.restart(e.member); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// restart restarts the workers according to the strategy,
+// it returns an error if the restart intensity is exceeded
+func (s *supervisor) restart(exited *member) error {
+	g := s.group
+
+	g.changeMu.Lock()
+	defer g.changeMu.Unlock()
+
+	if s.isStopping() {
+		return nil
+	}
+
+	members := g.snapshot()
+	i := indexOfMember(members, exited)
+	if i < 0 {
+		return nil
+	}
+
+	reason := exited.worker.Err()
+
+	if !s.allow() {
+		s.stop()
+		s.shutdown(members)
+		return fmt.Errorf("%w: %v", ErrTooManyRestarts, MemberError{Index: i, Name: exited.name, Err: reason})
+	}
+
+	first, last := i, i
+	switch s.config.strategy {
+	case OneForAll:
+		first, last = 0, len(members)-1
+	case RestForOne:
+		last = len(members) - 1
+	}
+
+	s.shutdown(members[first : last+1])
+
+	for j := first; j <= last; j++ {
+		m := members[j]
+		restart := Restart{Index: j, Name: m.name, Err: m.worker.RunContext(valueContext{s.ctx})}
+		if j == i {
+			restart.Reason = reason
+		}
+
+		// the failed worker is monitored anyway, so its exit triggers another restart
+		s.monitor(m)
+		g.events.OnRestart(restart)
+	}
+
+	return nil
+}
+
+// shutdown stops the members in the reverse start order
+func (s *supervisor) shutdown(members []*member) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.group.rollbackTimeout)
+	defer cancel()
+
+	for j := len(members) - 1; j >= 0; j-- {
+		// the error is ignored, the worker may have exited already
+		_ = members[j].worker.Shutdown(ctx)
+	}
+}
+
+// allow registers the restart if the intensity is not exceeded
+func (s *supervisor) allow() bool {
+	now := time.Now()
+
+	restarts := s.restarts[:0]
+	for _, t := range s.restarts {
+		if now.Sub(t) < s.config.window {
+			restarts = append(restarts, t)
+		}
+	}
+	s.restarts = restarts
+
+	if len(s.restarts) >= s.config.maxRestarts {
+		return false
+	}
+
+	s.restarts = append(s.restarts, now)
+
+	return true
+}
+
+func (s *supervisor) isStopping() bool {
+	select {
+	case <-s.stopping:
+		return true
+	case <-s.ctx.Done():
+		return true
+	default:
+		return false
+	}
+}
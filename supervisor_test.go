@@ -0,0 +1,162 @@
+package porter
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSupervisor(t *testing.T) {
+	panicOnce := func() JobFunc {
+		var jobs int64
+		return func(state State) error {
+			if atomic.AddInt64(&jobs, 1) == 1 {
+				panic("test panic")
+			}
+			return nil
+		}
+	}
+	nopJobFunc := func(state State) error {
+		return nil
+	}
+
+	t.Run("OneForOne", func(t *testing.T) {
+		restarts := make(chan Restart, 10)
+
+		crashing := NewWorker(panicOnce(), WithExitOnPanic(), WithSuccessTimeout(1*time.Second))
+		healthy := NewWorker(nopJobFunc, WithSuccessTimeout(1*time.Second))
+		g, err := NewGroup(
+			WithWorkers(healthy, crashing),
+			WithSupervisor(OneForOne, 3, time.Minute),
+			WithGroupSubscriber(func(s Subscriber) {
+				s.ListenRestart(func(r Restart) {
+					restarts <- r
+				})
+			}),
+		)
+		assert.NoError(t, err)
+
+		assert.NoError(t, g.Run())
+
+		r := <-restarts
+		assert.Equal(t, 1, r.Index)
+		assert.NoError(t, r.Err)
+		if assert.Error(t, r.Reason) {
+			assert.Contains(t, r.Reason.Error(), "porter: panic test panic")
+		}
+
+		assert.NoError(t, g.Shutdown(context.Background()))
+		assert.Len(t, restarts, 0)
+		assert.NoError(t, g.Err())
+	})
+
+	t.Run("RestForOne", func(t *testing.T) {
+		restarts := make(chan Restart, 10)
+
+		first := NewWorker(nopJobFunc, WithSuccessTimeout(1*time.Second))
+		second := NewWorker(nopJobFunc, WithSuccessTimeout(1*time.Second))
+		third := NewWorker(nopJobFunc, WithSuccessTimeout(1*time.Second))
+		g, err := NewGroup(
+			WithWorkers(first, second, third),
+			WithSupervisor(RestForOne, 3, time.Minute),
+			WithGroupSubscriber(func(s Subscriber) {
+				s.ListenRestart(func(r Restart) {
+					restarts <- r
+				})
+			}),
+		)
+		assert.NoError(t, err)
+
+		assert.NoError(t, g.Run())
+
+		// the worker stopped bypassing the group is restarted with the workers after it
+		assert.NoError(t, second.Shutdown(context.Background()))
+		assert.Equal(t, 1, (<-restarts).Index)
+		assert.Equal(t, 2, (<-restarts).Index)
+
+		assert.NoError(t, g.Shutdown(context.Background()))
+		assert.Len(t, restarts, 0)
+	})
+
+	t.Run("TooManyRestarts", func(t *testing.T) {
+		crashing := NewWorker(
+			func(state State) error {
+				panic("test panic")
+			},
+			WithExitOnPanic(),
+		)
+		healthy := NewWorker(nopJobFunc, WithSuccessTimeout(1*time.Second))
+		g, err := NewGroup(
+			WithWorkers(healthy, crashing),
+			WithSupervisor(OneForAll, 2, time.Minute),
+		)
+		assert.NoError(t, err)
+
+		assert.NoError(t, g.Run())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+		defer cancel()
+
+		assert.NoError(t, g.Wait(ctx))
+		assert.True(t, errors.Is(g.Err(), ErrTooManyRestarts))
+		assert.Equal(t, ErrWorkerClosed, healthy.Shutdown(context.Background()))
+	})
+
+	t.Run("InvalidIntensity", func(t *testing.T) {
+		crashing := NewWorker(
+			func(state State) error {
+				panic("test panic")
+			},
+			WithExitOnPanic(),
+		)
+		g, err := NewGroup(
+			WithWorkers(crashing),
+			WithSupervisor(OneForOne, 0, 0),
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, supervisorConfig{maxRestarts: defaultMaxRestarts, window: defaultRestartWindow}, *g.(*workerGroup).supervisor)
+
+		assert.NoError(t, g.Run())
+
+		// the restarts are counted, so the crashing worker is not restarted endlessly
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+		defer cancel()
+
+		assert.NoError(t, g.Wait(ctx))
+		assert.True(t, errors.Is(g.Err(), ErrTooManyRestarts))
+	})
+
+	t.Run("Members", func(t *testing.T) {
+		restarts := make(chan Restart, 10)
+
+		g, err := NewGroup(
+			WithMember("db", NewWorker(nopJobFunc, WithSuccessTimeout(1*time.Second))),
+			WithSupervisor(OneForOne, 3, time.Minute),
+			WithGroupSubscriber(func(s Subscriber) {
+				s.ListenRestart(func(r Restart) {
+					restarts <- r
+				})
+			}),
+		)
+		assert.NoError(t, err)
+		assert.NoError(t, g.Run())
+
+		// the added worker is supervised
+		tenant := NewWorker(nopJobFunc, WithSuccessTimeout(1*time.Second))
+		assert.NoError(t, g.Add("tenant", tenant, "db"))
+		assert.NoError(t, tenant.Shutdown(context.Background()))
+		assert.Equal(t, "tenant", (<-restarts).Name)
+
+		// the removed worker is not restarted
+		assert.NoError(t, g.Remove(context.Background(), "tenant"))
+		time.Sleep(50 * time.Millisecond)
+		assert.Len(t, restarts, 0)
+
+		assert.NoError(t, g.Shutdown(context.Background()))
+		assert.Len(t, restarts, 0)
+	})
+}
@@ -3,6 +3,7 @@ package porter
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 )
@@ -11,16 +12,78 @@ var (
 	ErrAlreadyRunning = errors.New("worker already running")
 	ErrWorkerClosed   = errors.New("worker is closed")
 	ErrIdleJob        = errors.New("idle job")
+	ErrNotPaused      = errors.New("worker is not paused")
+	ErrJobsLimit      = errors.New("jobs limit must be positive")
 )
 
+// ShutdownError is returned when the worker did not stop before the shutdown deadline
+type ShutdownError struct {
+	// The reason why the shutdown was interrupted
+	Err error
+	// Jobs that were still running at the deadline
+	Jobs []RunningJob
+}
+
+// RunningJob describes a job in progress
+type RunningJob struct {
+	// Job identifier, it is set by JobIDMiddleware
+	ID string
+	// Index of the executor running the job
+	Executor int
+	// Time when the job was started
+	StartedAt time.Time
+}
+
+func newShutdownError(err error, jobs []RunningJob) error {
+	if len(jobs) == 0 {
+		return err
+	}
+
+	return &ShutdownError{Err: err, Jobs: jobs}
+}
+
+func (e *ShutdownError) Error() string {
+	return fmt.Sprintf("%v: %d jobs still running", e.Err, len(e.Jobs))
+}
+
+func (e *ShutdownError) Unwrap() error {
+	return e.Err
+}
+
 const (
-	defaultJobsLimit           = 1
-	defaultShutdownPollTimeout = 500 * time.Millisecond
+	defaultJobsLimit = 1
 )
 
 type Worker interface {
 	Run() error
+	// RunContext starts the worker, cancellation of the context shuts the worker down
+	RunContext(ctx context.Context) error
 	Shutdown(ctx context.Context) error
+	// Done returns a channel that is closed when the worker loop exits
+	Done() <-chan struct{}
+	// Wait blocks until the worker loop exits or the context is done
+	Wait(ctx context.Context) error
+	// Pause stops starting new jobs and waits for the jobs in progress to finish,
+	// the worker stays paused even if the context is done before
+	Pause(ctx context.Context) error
+	// Resume continues starting new jobs after Pause
+	Resume() error
+	// SetJobsLimit changes the number of concurrent jobs, it takes effect immediately if the worker is running.
+	// When the limit is decreased, the excess jobs in progress are not interrupted.
+	// With WithAdaptiveConcurrency the limit is clamped to MinLimit and MaxLimit, and the next windows keep adjusting it
+	SetJobsLimit(limit int) error
+	// Err returns the reason why the worker loop exited by itself, e.g. a panic with WithExitOnPanic.
+	// It is nil while the worker is running or if it was shut down
+	Err() error
+	// Status returns the stage of the worker lifecycle
+	Status() Status
+	// Stats returns the counters of the worker jobs
+	Stats() Stats
+	// Subscriber allows to subscribe to the events at any time, e.g. while the worker is running
+	Subscriber() Subscriber
+	// Events returns the channel of the events emitted after the first call, the same channel is returned every time.
+	// The events are dropped if the channel is full, see WithEventsBuffer
+	Events() <-chan Event
 }
 
 type JobFunc func(state State) error
@@ -37,12 +100,11 @@ func WithJobsLimit(limit int) Opt {
 	}
 }
 
-func WithShutdownPollTimeout(shutdownPollTimeout time.Duration) Opt {
-	return func(w *worker) {
-		if shutdownPollTimeout > 0 {
-			w.shutdownPollTimeout = shutdownPollTimeout
-		}
-	}
+// WithShutdownPollTimeout used to set the interval of checking that the worker has stopped.
+//
+// Deprecated: Shutdown waits for the worker loop to exit without polling, the option has no effect.
+func WithShutdownPollTimeout(_ time.Duration) Opt {
+	return nil
 }
 
 // WithRunDelay adds a delay before worker starts
@@ -62,6 +124,23 @@ func WithSubscriber(subscribers ...func(subscriber Subscriber)) Opt {
 	}
 }
 
+// WithShutdownGracePeriod delays the cancellation of the jobs context after the shutdown starts
+func WithShutdownGracePeriod(period time.Duration) Opt {
+	return func(w *worker) {
+		if period > 0 {
+			w.config.gracePeriod = period
+		}
+	}
+}
+
+// WithExitOnPanic stops the worker when a job panics instead of crashing the process,
+// the panic is returned by Err. It is intended for the workers restarted by a supervisor
+func WithExitOnPanic() Opt {
+	return func(w *worker) {
+		w.config.exitOnPanic = true
+	}
+}
+
 // WithErrorTimeout adds a delay after the job that returned the error
 func WithErrorTimeout(timeout time.Duration) Opt {
 	return func(w *worker) {
@@ -85,9 +164,9 @@ func WithSuccessTimeout(timeout time.Duration) Opt {
 
 func NewWorker(jobFunc JobFunc, opts ...Opt) Worker {
 	w := &worker{
-		jobFunc:             jobFunc,
-		events:              &Dispatcher{},
-		shutdownPollTimeout: defaultShutdownPollTimeout,
+		jobFunc:  jobFunc,
+		events:   &Dispatcher{},
+		counters: &counters{},
 		config: workerConfig{
 			jobsLimit: defaultJobsLimit,
 		},
@@ -109,12 +188,16 @@ type worker struct {
 	done <-chan struct{}
 	// While the channel is open, the worker will start new tasks
 	closed chan struct{}
+	// Executors of the running worker
+	pool *pool
 	// Events handler
 	events *Dispatcher
-	// Timeout between attempts to stop the worker
-	shutdownPollTimeout time.Duration
+	// Events delivered by the channel
+	stream eventStream
 	// The task that the worker performs
 	jobFunc JobFunc
+	// Counters of the jobs of all the runs
+	counters *counters
 
 	config workerConfig
 }
@@ -123,19 +206,29 @@ type workerConfig struct {
 	jobsLimit      int
 	delay          time.Duration
 	errorTimeout   time.Duration
+	errorBackoff   Backoff
 	successTimeout time.Duration
 	idleTimeout    time.Duration
+	errorClasses   []errorClass
+	adaptive       *AdaptiveConcurrency
+	rateLimiter    *RateLimiter
+	gracePeriod    time.Duration
+	exitOnPanic    bool
 	middlewares    []MiddlewareFunc
 }
 
 func (w *worker) Run() error {
-	err := w.run()
+	return w.RunContext(context.Background())
+}
+
+func (w *worker) RunContext(ctx context.Context) error {
+	err := w.run(ctx)
 	w.events.OnRun(err)
 
 	return err
 }
 
-func (w *worker) run() error {
+func (w *worker) run(ctx context.Context) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
@@ -147,114 +240,257 @@ func (w *worker) run() error {
 		}
 	}
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	w.closed = make(chan struct{})
-	w.done = runWorker(w.jobFunc, w.config, w.closed)
+	w.pool = newPool(ctx, w.jobFunc, w.config, w.events, w.counters, w.closed)
+	w.done = w.pool.done
+
+	if ctx.Done() != nil {
+		go w.shutdownOnCancel(ctx, w.closed, w.done)
+	}
 
 	return nil
 }
 
+// shutdownOnCancel shuts the worker down when the context passed to RunContext is cancelled,
+// it takes the same path as Shutdown without a deadline
+func (w *worker) shutdownOnCancel(ctx context.Context, closed, done <-chan struct{}) {
+	select {
+	case <-closed:
+		return
+	case <-done:
+		return
+	case <-ctx.Done():
+	}
+
+	_ = w.stop(context.Background(), closed)
+}
+
+// Shutdown stops the worker, the events delivered asynchronously are flushed within the same context
 func (w *worker) Shutdown(ctx context.Context) error {
-	err := w.shutdown(ctx)
+	return w.stop(ctx, nil)
+}
+
+// stop shuts the worker down and emits the result, if closed is not nil, only the run it belongs to is stopped
+// and nothing is emitted if the run is already stopped
+func (w *worker) stop(ctx context.Context, closed <-chan struct{}) error {
+	err := w.shutdown(ctx, closed)
+	if closed != nil && err == ErrWorkerClosed {
+		return err
+	}
+
 	w.events.OnShutdown(err)
 
+	// the worker is stopped anyway, so the events that didn't make it in time are not an error
+	_ = w.events.Flush(ctx)
+
 	return err
 }
 
-func (w *worker) shutdown(ctx context.Context) error {
+func (w *worker) shutdown(ctx context.Context, closed <-chan struct{}) error {
+	p, done, err := w.close(closed)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return newShutdownError(ctx.Err(), p.running())
+	}
+}
+
+// close stops starting new jobs and returns the running executors,
+// if closed is not nil, only the run it belongs to is stopped
+func (w *worker) close(closed <-chan struct{}) (*pool, <-chan struct{}, error) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	if w.closed == nil {
-		return ErrWorkerClosed
+	if w.closed == nil || (closed != nil && closed != w.closed) {
+		return nil, nil, ErrWorkerClosed
 	}
 
 	select {
 	default:
 	case <-w.closed:
-		return ErrWorkerClosed
+		return nil, nil, ErrWorkerClosed
 	}
 
-	close(w.closed)
-
+	// the worker exited by itself, e.g. because of a panic with WithExitOnPanic
 	select {
 	default:
 	case <-w.done:
-		return ErrWorkerClosed
+		return nil, nil, ErrWorkerClosed
 	}
 
-	ticker := time.NewTicker(w.shutdownPollTimeout)
-	defer ticker.Stop()
+	w.pool.close()
 
-	for {
-		select {
-		case <-w.done:
-			return nil
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-ticker.C:
-		}
+	return w.pool, w.done, nil
+}
+
+func (w *worker) Pause(ctx context.Context) error {
+	err := w.pause(ctx)
+	w.events.OnPause(err)
+
+	return err
+}
+
+func (w *worker) pause(ctx context.Context) error {
+	p, err := w.running()
+	if err != nil {
+		return err
 	}
+
+	return p.pause(ctx)
 }
 
-func runWorker(fn JobFunc, config workerConfig, closed <-chan struct{}) <-chan struct{} {
-	done := make(chan struct{})
-	jobs := make(chan struct{}, config.jobsLimit)
+func (w *worker) Resume() error {
+	err := w.resume()
+	w.events.OnResume(err)
 
-	go func() {
-		defer func() {
-			done <- struct{}{}
-		}()
+	return err
+}
 
-		if config.delay > 0 {
-			select {
-			case <-closed:
-				return
-			case <-time.After(config.delay):
-			}
-		}
+func (w *worker) resume() error {
+	p, err := w.running()
+	if err != nil {
+		return err
+	}
 
-		for {
-			select {
-			default:
-			case <-closed:
-				return
-			}
-
-			jobs <- struct{}{}
-
-			// TODO use a worker pool to avoid running excess goroutines
-			go func() {
-				var err error
-
-				defer func() {
-					if timeout := getTimeout(config, err); timeout > 0 {
-						select {
-						case <-time.After(timeout):
-						case <-closed:
-						}
-					}
-
-					<-jobs
-				}()
-
-				s := &state{}
-				err = applyMiddleware(fn, config.middlewares...)(s)
-			}()
-		}
-	}()
+	if !p.resume() {
+		return ErrNotPaused
+	}
+
+	return nil
+}
+
+func (w *worker) SetJobsLimit(limit int) error {
+	if limit <= 0 {
+		return ErrJobsLimit
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// the limit is kept for the next runs
+	w.config.jobsLimit = limit
+	if w.pool != nil {
+		w.pool.resize(limit)
+	}
+
+	return nil
+}
+
+// running returns the executors of the running worker
+func (w *worker) running() (*pool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed == nil {
+		return nil, ErrWorkerClosed
+	}
+
+	select {
+	default:
+	case <-w.closed:
+		return nil, ErrWorkerClosed
+	}
+
+	return w.pool, nil
+}
+
+func (w *worker) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.pool == nil {
+		return nil
+	}
 
-	return done
+	return w.pool.Err()
+}
+
+func (w *worker) Status() Status {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.pool == nil {
+		return StatusIdle
+	}
+
+	return w.pool.status()
+}
+
+func (w *worker) Stats() Stats {
+	w.mu.Lock()
+	p := w.pool
+	w.mu.Unlock()
+
+	inflight := 0
+	if p != nil {
+		inflight = p.inFlight()
+	}
+
+	return w.counters.stats(inflight)
+}
+
+func (w *worker) Subscriber() Subscriber {
+	return w.events
+}
+
+func (w *worker) Events() <-chan Event {
+	return w.stream.channel(w.events)
+}
+
+func (w *worker) Done() <-chan struct{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.done == nil {
+		return closedChan
+	}
+
+	return w.done
+}
+
+func (w *worker) Wait(ctx context.Context) error {
+	return wait(ctx, w.Done())
+}
+
+// closedChan is returned by Done of the worker that has never been started
+var closedChan = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+func wait(ctx context.Context, done <-chan struct{}) error {
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-func getTimeout(c workerConfig, err error) time.Duration {
+// getTimeout returns the delay after the job, the streak is reset by any result except the error
+func getTimeout(c workerConfig, err error, streak *failureStreak) time.Duration {
 	timeout := time.Duration(0)
-	switch err {
-	case ErrIdleJob:
+	switch class := classifyError(c.errorClasses, err); class.class {
+	case ClassIdle:
+		streak.reset()
 		timeout = c.idleTimeout
-	case nil:
+	case ClassSuccess:
+		streak.reset()
 		timeout = c.successTimeout
+	case ClassError:
+		timeout = streak.next(c)
 	default:
-		timeout = c.errorTimeout
+		timeout = class.timeout
 	}
 
 	return timeout
@@ -43,6 +43,7 @@ func Benchmark(b *testing.B) {
 	}
 
 	b.Run("Default", run())
+	b.Run("WithJobsLimit", run(WithJobsLimit(64)))
 	b.Run("WithJobTTL", run(WithMiddleware(JobTTLMiddleware(1*time.Second))))
 	b.Run("WithJobID", run(WithMiddleware(JobIDMiddleware())))
 }
@@ -2,35 +2,606 @@ package porter
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
 )
 
+// GroupError aggregates the errors of the group members
+type GroupError struct {
+	Errors []MemberError
+}
+
+// MemberError is an error of the group member
+type MemberError struct {
+	// Position of the worker in the start order of the group
+	Index int
+	// Name of the worker added by WithMember
+	Name string
+	Err  error
+}
+
+func (e MemberError) Error() string {
+	if e.Name != "" {
+		return fmt.Sprintf("worker %q: %v", e.Name, e.Err)
+	}
+
+	return fmt.Sprintf("worker #%d: %v", e.Index, e.Err)
+}
+
+func (e MemberError) Unwrap() error {
+	return e.Err
+}
+
+func (e *GroupError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// Is reports whether any member error matches the target
+func (e *GroupError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// As finds the first member error that matches the target
+func (e *GroupError) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+const defaultRollbackTimeout = 10 * time.Second
+
+type GroupOpt func(g *workerGroup)
+
+// WithWorkers adds unnamed workers to the group, they are started in the given order
+func WithWorkers(workers ...Worker) GroupOpt {
+	return func(g *workerGroup) {
+		for _, w := range workers {
+			g.members = append(g.members, &member{worker: w})
+		}
+	}
+}
+
+// WithGroupSubscriber subscribes to the events of the group
+func WithGroupSubscriber(subscribers ...func(subscriber Subscriber)) GroupOpt {
+	return func(g *workerGroup) {
+		for _, subscribe := range subscribers {
+			subscribe(g.events)
+		}
+	}
+}
+
+// WithRollbackTimeout limits the shutdown of the workers stopped by the group itself:
+// the started workers when another worker fails to run and the workers restarted by the supervisor
+func WithRollbackTimeout(timeout time.Duration) GroupOpt {
+	return func(g *workerGroup) {
+		if timeout > 0 {
+			g.rollbackTimeout = timeout
+		}
+	}
+}
+
+// WorkerGroup is a group of workers that can be changed at runtime
+type WorkerGroup interface {
+	Worker
+	// Add adds the named worker to the group, the worker is started if the group is running
+	Add(name string, w Worker, dependsOn ...string) error
+	// Remove removes the worker from the group and shuts it down if the group is running
+	Remove(ctx context.Context, name string) error
+	// List returns the members of the group in the start order
+	List() []MemberInfo
+}
+
+// MemberInfo describes a worker of the group
+type MemberInfo struct {
+	Name      string
+	DependsOn []string
+	Worker    Worker
+	Status    Status
+}
+
 type workerGroup struct {
-	workers []Worker
+	mu sync.Mutex
+	// Members in the start order
+	members []*member
+	// Incremented on every change of the members
+	version int
+	// Context of the workers of the current run, it is nil if the group is not running.
+	// It keeps the values of the context passed to RunContext but not its cancellation,
+	// the group reacts to the cancellation by shutting the workers down in the dependency order
+	ctx context.Context
+	// The channel is closed when all the workers have exited
+	done <-chan struct{}
+	// Events handler
+	events *Dispatcher
+	// Events delivered by the channel
+	stream eventStream
+	// Timeout of the shutdown of the workers stopped by the group itself
+	rollbackTimeout time.Duration
+
+	// Serializes the changes of the group: runs, shutdowns, restarts and changes of the members
+	changeMu sync.Mutex
+
+	// Restarts the exited workers if not nil
+	supervisor *supervisorConfig
+	// Supervisor of the current run
+	sup *supervisor
+	// The reason why the supervised group stopped by itself
+	err error
+}
+
+func NewWorkerGroup(workers ...Worker) WorkerGroup {
+	// unnamed workers have no dependencies, so the group is always valid
+	g, _ := newWorkerGroup(WithWorkers(workers...))
+	return g
 }
 
-func NewWorkerGroup(workers ...Worker) Worker {
-	return &workerGroup{
-		workers: workers,
+// NewGroup creates a group of workers configured by the options,
+// it returns an error if the dependencies of the members are invalid
+func NewGroup(opts ...GroupOpt) (WorkerGroup, error) {
+	g, err := newWorkerGroup(opts...)
+	if err != nil {
+		return nil, err
 	}
+
+	return g, nil
+}
+
+func newWorkerGroup(opts ...GroupOpt) (*workerGroup, error) {
+	g := &workerGroup{
+		events:          &Dispatcher{},
+		rollbackTimeout: defaultRollbackTimeout,
+	}
+
+	for _, opt := range opts {
+		if opt != nil {
+			opt(g)
+		}
+	}
+
+	members, err := sortMembers(g.members)
+	if err != nil {
+		return nil, err
+	}
+	g.members = members
+
+	return g, nil
 }
 
 func (g *workerGroup) Run() error {
-	for _, w := range g.workers {
-		if err := w.Run(); err != nil {
-			return err
+	return g.RunContext(context.Background())
+}
+
+// RunContext starts all the workers or none of them:
+// if a worker fails to run, the workers started before are shut down.
+// Cancellation of the context shuts the group down the same way as Shutdown without a deadline
+func (g *workerGroup) RunContext(ctx context.Context) error {
+	err := g.run(ctx)
+	g.events.OnRun(err)
+
+	return err
+}
+
+func (g *workerGroup) run(ctx context.Context) error {
+	g.changeMu.Lock()
+	defer g.changeMu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	workerCtx := valueContext{ctx}
+
+	members := g.snapshot()
+	for i, m := range members {
+		if err := m.worker.RunContext(workerCtx); err != nil {
+			return g.rollback(members[:i], MemberError{Index: i, Name: m.name, Err: err})
 		}
 	}
 
+	done := make(chan struct{})
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.ctx = workerCtx
+	g.done = done
+	g.err = nil
+	g.sup = nil
+
+	if g.supervisor != nil {
+		g.sup = newSupervisor(g, ctx, members)
+	}
+
+	go g.wait(done)
+
+	if ctx.Done() != nil {
+		go g.shutdownOnCancel(ctx, done)
+	}
+
 	return nil
 }
 
+// shutdownOnCancel shuts the run of the group down when the context passed to RunContext is cancelled
+func (g *workerGroup) shutdownOnCancel(ctx context.Context, done <-chan struct{}) {
+	select {
+	case <-done:
+		return
+	case <-ctx.Done():
+	}
+
+	_ = g.stop(context.Background(), done)
+}
+
+// wait closes the channel when the supervisor has stopped and all the current members have exited
+func (g *workerGroup) wait(done chan struct{}) {
+	g.mu.Lock()
+	sup := g.sup
+	g.mu.Unlock()
+
+	if sup != nil {
+		if err := sup.run(); err != nil {
+			g.mu.Lock()
+			g.err = err
+			g.mu.Unlock()
+
+			g.events.OnShutdown(err)
+		}
+	}
+
+	for {
+		g.mu.Lock()
+		members, version := g.members, g.version
+		g.mu.Unlock()
+
+		for _, m := range members {
+			<-m.worker.Done()
+		}
+
+		g.mu.Lock()
+		if version == g.version {
+			if g.done == done {
+				g.ctx = nil
+			}
+			close(done)
+			g.mu.Unlock()
+
+			return
+		}
+		g.mu.Unlock()
+	}
+}
+
+// Shutdown stops the workers concurrently, so every worker has the whole deadline,
+// but the worker is stopped only after the workers that depend on it.
+// The events of the group are flushed within the same context, as by Worker.Shutdown
 func (g *workerGroup) Shutdown(ctx context.Context) error {
-	// TODO need to stop the workers concurrently, otherwise, it can not meet the timeout
-	for _, w := range g.workers {
-		if err := w.Shutdown(ctx); err != nil {
-			return err
+	return g.stop(ctx, nil)
+}
+
+// stop shuts the group down and emits the result, if done is not nil, only the run it belongs to is stopped
+// and nothing is emitted if the run is already stopped
+func (g *workerGroup) stop(ctx context.Context, done <-chan struct{}) error {
+	err := g.shutdown(ctx, done)
+	if done != nil && err == ErrWorkerClosed {
+		return err
+	}
+
+	g.events.OnShutdown(err)
+	_ = g.events.Flush(ctx)
+
+	return err
+}
+
+// shutdown stops the workers, if done is not nil, only the run it belongs to is stopped
+func (g *workerGroup) shutdown(ctx context.Context, done <-chan struct{}) error {
+	g.changeMu.Lock()
+
+	g.mu.Lock()
+	if done != nil && done != g.done {
+		g.mu.Unlock()
+		g.changeMu.Unlock()
+
+		return ErrWorkerClosed
+	}
+
+	members := g.members
+	// the workers added from now on are not started
+	g.ctx = nil
+	if g.sup != nil {
+		g.sup.stop()
+	}
+	g.mu.Unlock()
+
+	g.changeMu.Unlock()
+
+	err := stopMembers(members, func(w Worker) error {
+		return w.Shutdown(ctx)
+	})
+	if err != nil {
+		return err
+	}
+
+	return wait(ctx, g.Done())
+}
+
+// Pause pauses the workers concurrently to wait for their jobs within the same deadline
+func (g *workerGroup) Pause(ctx context.Context) error {
+	err := forEach(g.snapshot(), func(w Worker) error {
+		return w.Pause(ctx)
+	})
+	g.events.OnPause(err)
+
+	return err
+}
+
+func (g *workerGroup) Resume() error {
+	err := forEach(g.snapshot(), Worker.Resume)
+	g.events.OnResume(err)
+
+	return err
+}
+
+// SetJobsLimit sets the same jobs limit for every worker of the group
+func (g *workerGroup) SetJobsLimit(limit int) error {
+	return forEach(g.snapshot(), func(w Worker) error {
+		return w.SetJobsLimit(limit)
+	})
+}
+
+func (g *workerGroup) Err() error {
+	if g.supervisor != nil {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+
+		return g.err
+	}
+
+	members := g.snapshot()
+	errs := make([]error, len(members))
+	for i, m := range members {
+		errs[i] = m.worker.Err()
+	}
+
+	return newGroupError(members, errs)
+}
+
+// Status aggregates the statuses of the workers: the group is stopping if any worker is stopping,
+// otherwise it has the status of its most active worker, e.g. it is running if any worker is running
+func (g *workerGroup) Status() Status {
+	members := g.snapshot()
+
+	statuses := make([]Status, len(members))
+	for i, m := range members {
+		statuses[i] = m.worker.Status()
+	}
+
+	return groupStatus(statuses)
+}
+
+// Stats sums the counters of the current workers
+func (g *workerGroup) Stats() Stats {
+	var stats Stats
+	for _, m := range g.snapshot() {
+		stats = stats.add(m.worker.Stats())
+	}
+
+	return stats
+}
+
+// Subscriber returns the events of the group, the events of the workers are not included
+func (g *workerGroup) Subscriber() Subscriber {
+	return g.events
+}
+
+// Events returns the channel of the events of the group, the events of the workers are not included
+func (g *workerGroup) Events() <-chan Event {
+	return g.stream.channel(g.events)
+}
+
+func (g *workerGroup) Done() <-chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.done == nil {
+		return closedChan
+	}
+
+	return g.done
+}
+
+func (g *workerGroup) Wait(ctx context.Context) error {
+	return wait(ctx, g.Done())
+}
+
+func (g *workerGroup) Add(name string, w Worker, dependsOn ...string) error {
+	if name == "" {
+		return ErrUnnamedMember
+	}
+
+	g.changeMu.Lock()
+	defer g.changeMu.Unlock()
+
+	m := &member{
+		name:      name,
+		worker:    w,
+		dependsOn: dependsOn,
+	}
+
+	current := g.snapshot()
+	members, err := sortMembers(append(current[:len(current):len(current)], m))
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	ctx, sup := g.ctx, g.sup
+	g.mu.Unlock()
+
+	if ctx != nil {
+		if err := w.RunContext(ctx); err != nil {
+			return MemberError{Index: indexOfMember(members, m), Name: name, Err: err}
+		}
+	}
+
+	g.mu.Lock()
+	g.members = members
+	g.version++
+	g.mu.Unlock()
+
+	if sup != nil {
+		sup.monitor(m)
+	}
+
+	return nil
+}
+
+func (g *workerGroup) Remove(ctx context.Context, name string) error {
+	g.changeMu.Lock()
+	defer g.changeMu.Unlock()
+
+	g.mu.Lock()
+	var removed *member
+	members := make([]*member, 0, len(g.members))
+	for _, m := range g.members {
+		if m.name == name {
+			removed = m
+			continue
 		}
+		members = append(members, m)
+	}
+
+	if removed == nil {
+		g.mu.Unlock()
+		return fmt.Errorf("%w: %q", ErrUnknownMember, name)
+	}
+
+	for _, m := range members {
+		for _, dep := range m.dependsOn {
+			if dep == name {
+				g.mu.Unlock()
+				return fmt.Errorf("%w: %q is required by %q", ErrMemberInUse, name, m.name)
+			}
+		}
+	}
+
+	running, sup := g.ctx != nil, g.sup
+	g.members = members
+	g.version++
+	g.mu.Unlock()
+
+	if sup != nil {
+		sup.forget(removed)
+	}
+
+	if !running {
+		return nil
+	}
+
+	if err := removed.worker.Shutdown(ctx); err != nil && !errors.Is(err, ErrWorkerClosed) {
+		return err
 	}
 
 	return nil
 }
+
+func (g *workerGroup) List() []MemberInfo {
+	members := g.snapshot()
+
+	list := make([]MemberInfo, 0, len(members))
+	for _, m := range members {
+		list = append(list, MemberInfo{
+			Name:      m.name,
+			DependsOn: m.dependsOn,
+			Worker:    m.worker,
+			Status:    m.worker.Status(),
+		})
+	}
+
+	return list
+}
+
+// snapshot returns the current members, the slice is never modified in place
+func (g *workerGroup) snapshot() []*member {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.members
+}
+
+// rollback shuts down the started workers and returns the error of the worker that failed to run
+func (g *workerGroup) rollback(started []*member, err MemberError) error {
+	ctx, cancel := context.WithTimeout(context.Background(), g.rollbackTimeout)
+	defer cancel()
+
+	rollbackErr := stopMembers(started, func(w Worker) error {
+		return w.Shutdown(ctx)
+	})
+	if rollbackErr != nil {
+		return fmt.Errorf("%w, rollback failed: %v", err, rollbackErr)
+	}
+
+	return err
+}
+
+// forEach calls fn for every member concurrently and aggregates the errors into GroupError
+func forEach(members []*member, fn func(Worker) error) error {
+	errs := make([]error, len(members))
+
+	wg := sync.WaitGroup{}
+	wg.Add(len(members))
+
+	for i, m := range members {
+		go func(i int, w Worker) {
+			defer wg.Done()
+			errs[i] = fn(w)
+		}(i, m.worker)
+	}
+
+	wg.Wait()
+
+	return newGroupError(members, errs)
+}
+
+// newGroupError returns nil if there are no errors, errs are indexed by the position of the member
+func newGroupError(members []*member, errs []error) error {
+	var groupErr GroupError
+	for i, err := range errs {
+		if err != nil {
+			groupErr.Errors = append(groupErr.Errors, MemberError{Index: i, Name: members[i].name, Err: err})
+		}
+	}
+
+	if len(groupErr.Errors) == 0 {
+		return nil
+	}
+
+	return &groupErr
+}
+
+func indexOfMember(members []*member, m *member) int {
+	for i := range members {
+		if members[i] == m {
+			return i
+		}
+	}
+
+	return -1
+}
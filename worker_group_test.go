@@ -0,0 +1,400 @@
+package porter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkerGroup_Wait(t *testing.T) {
+	nopJobFunc := func(state State) error {
+		return nil
+	}
+
+	first := NewWorker(nopJobFunc, WithSuccessTimeout(1*time.Second))
+	second := NewWorker(nopJobFunc, WithSuccessTimeout(1*time.Second))
+	g := NewWorkerGroup(first, second)
+
+	assert.NoError(t, g.Wait(context.Background()))
+	assert.NoError(t, g.Run())
+
+	assert.NoError(t, first.Shutdown(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	assert.Equal(t, context.DeadlineExceeded, g.Wait(ctx))
+	assert.NoError(t, second.Shutdown(context.Background()))
+	assert.NoError(t, g.Wait(context.Background()))
+	<-g.Done()
+}
+
+func TestWorkerGroup_Status(t *testing.T) {
+	nopJobFunc := func(state State) error {
+		return nil
+	}
+
+	first := NewWorker(nopJobFunc, WithSuccessTimeout(1*time.Second))
+	second := NewWorker(nopJobFunc, WithSuccessTimeout(1*time.Second))
+	g := NewWorkerGroup(first, second)
+
+	assert.Equal(t, StatusIdle, g.Status())
+	assert.NoError(t, g.Run())
+
+	assert.Eventually(t, func() bool {
+		return g.Stats().Succeeded == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, Stats{Started: 2, Succeeded: 2}, g.Stats())
+
+	assert.NoError(t, first.Pause(context.Background()))
+	assert.Equal(t, StatusRunning, g.Status())
+	assert.NoError(t, second.Pause(context.Background()))
+	assert.Equal(t, StatusPaused, g.Status())
+
+	assert.NoError(t, first.Shutdown(context.Background()))
+	assert.Equal(t, StatusPaused, g.Status())
+
+	assert.NoError(t, second.Shutdown(context.Background()))
+	assert.Equal(t, StatusStopped, g.Status())
+}
+
+func TestWorkerGroup_Shutdown(t *testing.T) {
+	nopJobFunc := func(state State) error {
+		return nil
+	}
+	blockingJobFunc := func(state State) error {
+		<-state.Context().Done()
+		return nil
+	}
+
+	t.Run("Concurrent", func(t *testing.T) {
+		slow := []Worker{
+			NewWorker(blockingJobFunc, WithShutdownGracePeriod(100*time.Millisecond)),
+			NewWorker(blockingJobFunc, WithShutdownGracePeriod(100*time.Millisecond)),
+			NewWorker(blockingJobFunc, WithShutdownGracePeriod(100*time.Millisecond)),
+		}
+		g := NewWorkerGroup(slow...)
+
+		assert.NoError(t, g.Run())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+		defer cancel()
+
+		assert.NoError(t, g.Shutdown(ctx))
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		running := NewWorker(nopJobFunc, WithSuccessTimeout(1*time.Second))
+		stopped := NewWorker(nopJobFunc)
+		g := NewWorkerGroup(running, stopped)
+
+		assert.NoError(t, running.Run())
+
+		err := g.Shutdown(context.Background())
+		assert.True(t, errors.Is(err, ErrWorkerClosed))
+
+		var groupErr *GroupError
+		if assert.True(t, errors.As(err, &groupErr)) && assert.Len(t, groupErr.Errors, 1) {
+			assert.Equal(t, 1, groupErr.Errors[0].Index)
+			assert.Equal(t, "worker #1: worker is closed", err.Error())
+		}
+
+		assert.NoError(t, running.Wait(context.Background()))
+	})
+}
+
+func TestWorkerGroup_Run(t *testing.T) {
+	nopJobFunc := func(state State) error {
+		return nil
+	}
+
+	t.Run("Rollback", func(t *testing.T) {
+		first := NewWorker(nopJobFunc, WithSuccessTimeout(1*time.Second))
+		second := NewWorker(nopJobFunc, WithSuccessTimeout(1*time.Second))
+		third := NewWorker(nopJobFunc, WithSuccessTimeout(1*time.Second))
+		g, err := NewGroup(
+			WithWorkers(first, second, third),
+			WithRollbackTimeout(1*time.Second),
+		)
+		assert.NoError(t, err)
+
+		assert.NoError(t, third.Run())
+
+		err = g.Run()
+		assert.True(t, errors.Is(err, ErrAlreadyRunning))
+
+		var memberErr MemberError
+		if assert.True(t, errors.As(err, &memberErr)) {
+			assert.Equal(t, 2, memberErr.Index)
+		}
+
+		// the started workers are stopped
+		assert.NoError(t, first.Wait(context.Background()))
+		assert.NoError(t, second.Wait(context.Background()))
+		assert.Equal(t, ErrWorkerClosed, first.Shutdown(context.Background()))
+		assert.NoError(t, third.Shutdown(context.Background()))
+	})
+}
+
+func TestWorkerGroup_RunContext(t *testing.T) {
+	shutdown := make(chan error, 1)
+
+	w := NewWorker(
+		func(state State) error {
+			return nil
+		},
+		WithSuccessTimeout(1*time.Second),
+	)
+	g, err := NewGroup(
+		WithWorkers(w),
+		WithGroupSubscriber(func(s Subscriber) {
+			s.ListenShutdown(func(err error) {
+				shutdown <- err
+			})
+		}),
+		WithGroupAsyncEvents(10, DropOnOverflow),
+	)
+	assert.NoError(t, err)
+
+	events := g.Events()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	assert.NoError(t, g.RunContext(ctx))
+	assert.Equal(t, EventRun, (<-events).Type)
+
+	// the cancellation takes the same path as Shutdown
+	cancel()
+	assert.NoError(t, <-shutdown)
+	assert.Equal(t, EventShutdown, (<-events).Type)
+	assert.Equal(t, StatusStopped, w.Status())
+}
+
+func TestWorkerGroup_Dependencies(t *testing.T) {
+	nopJobFunc := func(state State) error {
+		return nil
+	}
+
+	t.Run("Order", func(t *testing.T) {
+		var events []string
+		mu := sync.Mutex{}
+		record := func(name string) func(Subscriber) {
+			return func(s Subscriber) {
+				s.ListenRun(func(_ error) {
+					mu.Lock()
+					events = append(events, "run "+name)
+					mu.Unlock()
+				})
+				s.ListenShutdown(func(_ error) {
+					mu.Lock()
+					events = append(events, "shutdown "+name)
+					mu.Unlock()
+				})
+			}
+		}
+
+		newWorker := func(name string) Worker {
+			return NewWorker(
+				nopJobFunc,
+				WithSuccessTimeout(1*time.Second),
+				WithSubscriber(record(name)),
+			)
+		}
+
+		g, err := NewGroup(
+			WithMember("flusher", newWorker("flusher"), "producer", "db"),
+			WithMember("producer", newWorker("producer"), "db"),
+			WithMember("db", newWorker("db")),
+		)
+		assert.NoError(t, err)
+
+		assert.NoError(t, g.Run())
+		assert.NoError(t, g.Shutdown(context.Background()))
+
+		assert.Equal(t, []string{
+			"run db",
+			"run producer",
+			"run flusher",
+			"shutdown flusher",
+			"shutdown producer",
+			"shutdown db",
+		}, events)
+	})
+
+	t.Run("CancelOrder", func(t *testing.T) {
+		var events []string
+		mu := sync.Mutex{}
+		newWorker := func(name string) Worker {
+			return NewWorker(
+				nopJobFunc,
+				WithSuccessTimeout(1*time.Second),
+				WithSubscriber(func(s Subscriber) {
+					s.ListenShutdown(func(_ error) {
+						mu.Lock()
+						events = append(events, name)
+						mu.Unlock()
+					})
+				}),
+			)
+		}
+
+		g, err := NewGroup(
+			WithMember("producer", newWorker("producer"), "flusher"),
+			WithMember("flusher", newWorker("flusher")),
+		)
+		assert.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		assert.NoError(t, g.RunContext(ctx))
+
+		// the cancellation stops the workers in the same order as Shutdown
+		cancel()
+		assert.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return len(events) == 2
+		}, time.Second, 10*time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, []string{"producer", "flusher"}, events)
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		_, err := NewGroup(
+			WithMember("a", NewWorker(nopJobFunc), "c"),
+			WithMember("b", NewWorker(nopJobFunc), "a"),
+			WithMember("c", NewWorker(nopJobFunc), "b"),
+		)
+
+		assert.True(t, errors.Is(err, ErrDependencyCycle))
+		assert.EqualError(t, err, "dependency cycle: a -> c -> b -> a")
+	})
+
+	t.Run("UnknownDependency", func(t *testing.T) {
+		_, err := NewGroup(
+			WithMember("a", NewWorker(nopJobFunc), "b"),
+		)
+
+		assert.True(t, errors.Is(err, ErrUnknownDependency))
+	})
+
+	t.Run("DuplicateMember", func(t *testing.T) {
+		_, err := NewGroup(
+			WithMember("a", NewWorker(nopJobFunc)),
+			WithMember("a", NewWorker(nopJobFunc)),
+		)
+
+		assert.True(t, errors.Is(err, ErrDuplicateMember))
+	})
+
+	t.Run("MemberError", func(t *testing.T) {
+		running := NewWorker(nopJobFunc, WithSuccessTimeout(1*time.Second))
+		g, err := NewGroup(
+			WithMember("db", NewWorker(nopJobFunc)),
+			WithMember("producer", running, "db"),
+		)
+		assert.NoError(t, err)
+
+		assert.NoError(t, running.Run())
+		assert.EqualError(t, g.Run(), `worker "producer": worker already running`)
+		assert.NoError(t, running.Shutdown(context.Background()))
+	})
+}
+
+func TestWorkerGroup_Members(t *testing.T) {
+	nopJobFunc := func(state State) error {
+		return nil
+	}
+
+	t.Run("Add", func(t *testing.T) {
+		g, err := NewGroup(WithMember("db", NewWorker(nopJobFunc, WithSuccessTimeout(1*time.Second))))
+		assert.NoError(t, err)
+
+		stopped := NewWorker(nopJobFunc)
+		assert.NoError(t, g.Add("stopped", stopped, "db"))
+		// the worker is not started while the group is not running
+		assert.Equal(t, ErrWorkerClosed, stopped.Shutdown(context.Background()))
+
+		assert.NoError(t, g.Run())
+
+		tenant := NewWorker(nopJobFunc, WithSuccessTimeout(1*time.Second))
+		assert.NoError(t, g.Add("tenant", tenant, "db"))
+		assert.Equal(t, ErrAlreadyRunning, tenant.Run())
+
+		assert.True(t, errors.Is(g.Add("tenant", NewWorker(nopJobFunc)), ErrDuplicateMember))
+		assert.True(t, errors.Is(g.Add("other", NewWorker(nopJobFunc), "unknown"), ErrUnknownDependency))
+		assert.Equal(t, ErrUnnamedMember, g.Add("", NewWorker(nopJobFunc)))
+
+		assert.NoError(t, g.Shutdown(context.Background()))
+		assert.Equal(t, ErrWorkerClosed, tenant.Shutdown(context.Background()))
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		db := NewWorker(nopJobFunc, WithSuccessTimeout(1*time.Second))
+		tenant := NewWorker(nopJobFunc, WithSuccessTimeout(1*time.Second))
+		g, err := NewGroup(
+			WithMember("db", db),
+			WithMember("tenant", tenant, "db"),
+		)
+		assert.NoError(t, err)
+		assert.NoError(t, g.Run())
+
+		assert.True(t, errors.Is(g.Remove(context.Background(), "db"), ErrMemberInUse))
+		assert.True(t, errors.Is(g.Remove(context.Background(), "unknown"), ErrUnknownMember))
+
+		assert.NoError(t, g.Remove(context.Background(), "tenant"))
+		assert.Equal(t, ErrWorkerClosed, tenant.Shutdown(context.Background()))
+		assert.Len(t, g.List(), 1)
+
+		// the group is over when the remaining worker exits
+		assert.NoError(t, db.Shutdown(context.Background()))
+		assert.NoError(t, g.Wait(context.Background()))
+	})
+
+	t.Run("List", func(t *testing.T) {
+		g, err := NewGroup(
+			WithMember("tenant", NewWorker(nopJobFunc, WithSuccessTimeout(1*time.Second)), "db"),
+			WithMember("db", NewWorker(nopJobFunc, WithSuccessTimeout(1*time.Second))),
+		)
+		assert.NoError(t, err)
+		assert.NoError(t, g.Run())
+
+		list := g.List()
+		if assert.Len(t, list, 2) {
+			assert.Equal(t, "db", list[0].Name)
+			assert.Equal(t, "tenant", list[1].Name)
+			assert.Equal(t, []string{"db"}, list[1].DependsOn)
+			assert.Equal(t, StatusRunning, list[1].Status)
+		}
+
+		assert.NoError(t, g.Shutdown(context.Background()))
+		assert.Equal(t, StatusStopped, g.List()[0].Status)
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		g, err := NewGroup(WithMember("db", NewWorker(nopJobFunc, WithSuccessTimeout(1*time.Second))))
+		assert.NoError(t, err)
+		assert.NoError(t, g.Run())
+
+		wg := sync.WaitGroup{}
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(name string) {
+				defer wg.Done()
+				assert.NoError(t, g.Add(name, NewWorker(nopJobFunc, WithSuccessTimeout(1*time.Second)), "db"))
+				g.List()
+				assert.NoError(t, g.Remove(context.Background(), name))
+			}(fmt.Sprintf("tenant-%d", i))
+		}
+		wg.Wait()
+
+		assert.Len(t, g.List(), 1)
+		assert.NoError(t, g.Shutdown(context.Background()))
+	})
+}
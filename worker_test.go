@@ -21,7 +21,6 @@ func TestNewWorker(t *testing.T) {
 		wrk := NewWorker(nopJobFunc, nil)
 		w := wrk.(*worker)
 
-		assert.Equal(t, defaultShutdownPollTimeout, w.shutdownPollTimeout)
 		assert.Equal(t, defaultJobsLimit, w.config.jobsLimit)
 		assert.Equal(t, time.Duration(0), w.config.idleTimeout)
 		assert.Equal(t, time.Duration(0), w.config.successTimeout)
@@ -34,7 +33,7 @@ func TestNewWorker(t *testing.T) {
 		idleTimeout := 3 * time.Second
 		successTimeout := 4 * time.Second
 		errorTimeout := 5 * time.Second
-		shutdownPollTimeout := 6 * time.Second
+		gracePeriod := 7 * time.Second
 
 		wrk := NewWorker(
 			nopJobFunc,
@@ -42,18 +41,18 @@ func TestNewWorker(t *testing.T) {
 			WithIdleTimeout(idleTimeout),
 			WithSuccessTimeout(successTimeout),
 			WithErrorTimeout(errorTimeout),
-			WithShutdownPollTimeout(shutdownPollTimeout),
+			WithShutdownGracePeriod(gracePeriod),
 			WithMiddleware(
 				JobTTLMiddleware(jobTTL),
 			),
 		)
 		w := wrk.(*worker)
 
-		assert.Equal(t, shutdownPollTimeout, w.shutdownPollTimeout)
 		assert.Equal(t, jobsLimit, w.config.jobsLimit)
 		assert.Equal(t, idleTimeout, w.config.idleTimeout)
 		assert.Equal(t, successTimeout, w.config.successTimeout)
 		assert.Equal(t, errorTimeout, w.config.errorTimeout)
+		assert.Equal(t, gracePeriod, w.config.gracePeriod)
 	})
 }
 
@@ -96,6 +95,81 @@ func TestWorker_Run(t *testing.T) {
 	})
 }
 
+func TestWorker_RunContext(t *testing.T) {
+	type contextKey struct{}
+
+	t.Run("Cancel", func(t *testing.T) {
+		values := make(chan interface{}, 1)
+		shutdown := make(chan error, 1)
+
+		w := NewWorker(
+			func(state State) error {
+				select {
+				case values <- state.Context().Value(contextKey{}):
+				default:
+				}
+
+				return nil
+			},
+			WithSuccessTimeout(1*time.Second),
+			WithSubscriber(func(s Subscriber) {
+				s.ListenShutdown(func(err error) {
+					shutdown <- err
+				})
+			}),
+		)
+
+		ctx, cancel := context.WithCancel(context.WithValue(context.Background(), contextKey{}, "value"))
+
+		assert.NoError(t, w.RunContext(ctx))
+		assert.Equal(t, "value", <-values)
+
+		cancel()
+
+		assert.NoError(t, <-shutdown)
+		assert.NoError(t, w.Wait(context.Background()))
+		assert.Equal(t, ErrWorkerClosed, w.Shutdown(context.Background()))
+	})
+
+	t.Run("CancelAsyncEvents", func(t *testing.T) {
+		shutdown := make(chan error, 1)
+
+		w := NewWorker(
+			func(state State) error {
+				return nil
+			},
+			WithSuccessTimeout(1*time.Second),
+			WithSubscriber(func(s Subscriber) {
+				s.ListenShutdown(func(err error) {
+					shutdown <- err
+				})
+			}),
+			WithAsyncEvents(10, DropOnOverflow),
+		)
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		assert.NoError(t, w.RunContext(ctx))
+		cancel()
+
+		assert.NoError(t, <-shutdown)
+		assert.Equal(t, ErrWorkerClosed, w.Shutdown(context.Background()))
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		w := NewWorker(
+			func(state State) error {
+				return nil
+			},
+		)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		assert.Equal(t, context.Canceled, w.RunContext(ctx))
+	})
+}
+
 func TestWorker_Shutdown(t *testing.T) {
 	t.Run("NotRunning", func(t *testing.T) {
 		w := NewWorker(
@@ -109,7 +183,7 @@ func TestWorker_Shutdown(t *testing.T) {
 	})
 
 	t.Run("ContextDeadline", func(t *testing.T) {
-		const shutdownPoolTimeout = 200 * time.Millisecond
+		const shutdownTimeout = 100 * time.Millisecond
 		eventHandled := false
 
 		w := NewWorker(
@@ -117,7 +191,6 @@ func TestWorker_Shutdown(t *testing.T) {
 				return nil
 			},
 			WithSuccessTimeout(1*time.Second),
-			WithShutdownPollTimeout(shutdownPoolTimeout),
 			WithSubscriber(func(s Subscriber) {
 				s.ListenShutdown(func(err error) {
 					eventHandled = true
@@ -126,11 +199,11 @@ func TestWorker_Shutdown(t *testing.T) {
 			}),
 		)
 
-		assert.Nil(t, w.(*worker).done)
+		assert.Equal(t, StatusIdle, w.Status())
 		assert.NoError(t, w.Run())
-		assert.NotNil(t, w.(*worker).done)
+		assert.Equal(t, StatusRunning, w.Status())
 
-		ctx, cancel := context.WithTimeout(context.Background(), shutdownPoolTimeout/2)
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 		defer cancel()
 
 		w.(*worker).done = make(chan struct{})
@@ -139,7 +212,7 @@ func TestWorker_Shutdown(t *testing.T) {
 	})
 
 	t.Run("Success", func(t *testing.T) {
-		const shutdownPoolTimeout = 500 * time.Millisecond
+		const shutdownTimeout = 250 * time.Millisecond
 		eventHandled := false
 
 		w := NewWorker(
@@ -147,7 +220,6 @@ func TestWorker_Shutdown(t *testing.T) {
 				return nil
 			},
 			WithSuccessTimeout(1*time.Second),
-			WithShutdownPollTimeout(shutdownPoolTimeout),
 			WithSubscriber(func(s Subscriber) {
 				s.ListenShutdown(func(_ error) {
 					eventHandled = true
@@ -155,7 +227,7 @@ func TestWorker_Shutdown(t *testing.T) {
 			}),
 		)
 
-		ctx, cancel := context.WithTimeout(context.Background(), shutdownPoolTimeout/2)
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 		defer cancel()
 
 		assert.NoError(t, w.Run())
@@ -163,6 +235,87 @@ func TestWorker_Shutdown(t *testing.T) {
 		assert.True(t, eventHandled)
 	})
 
+	t.Run("CancelJobContext", func(t *testing.T) {
+		started := make(chan struct{})
+
+		w := NewWorker(
+			func(state State) error {
+				close(started)
+				<-state.Context().Done()
+
+				return state.Context().Err()
+			},
+			WithSuccessTimeout(1*time.Second),
+		)
+
+		assert.NoError(t, w.Run())
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+		defer cancel()
+
+		assert.NoError(t, w.Shutdown(ctx))
+	})
+
+	t.Run("GracePeriod", func(t *testing.T) {
+		const gracePeriod = 200 * time.Millisecond
+		started := make(chan struct{})
+
+		w := NewWorker(
+			func(state State) error {
+				close(started)
+				<-state.Context().Done()
+
+				return state.Context().Err()
+			},
+			WithSuccessTimeout(1*time.Second),
+			WithShutdownGracePeriod(gracePeriod),
+		)
+
+		assert.NoError(t, w.Run())
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+		defer cancel()
+
+		start := time.Now()
+		assert.NoError(t, w.Shutdown(ctx))
+		assert.GreaterOrEqual(t, int64(time.Since(start)), int64(gracePeriod))
+	})
+
+	t.Run("RunningJobs", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		defer close(release)
+
+		w := NewWorker(
+			func(state State) error {
+				close(started)
+				<-release
+
+				return nil
+			},
+			WithSuccessTimeout(1*time.Second),
+			WithShutdownGracePeriod(1*time.Second),
+			WithMiddleware(JobIDMiddleware()),
+		)
+
+		assert.NoError(t, w.Run())
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		err := w.Shutdown(ctx)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+		var shutdownErr *ShutdownError
+		if assert.True(t, errors.As(err, &shutdownErr)) && assert.Len(t, shutdownErr.Jobs, 1) {
+			assert.NotEmpty(t, shutdownErr.Jobs[0].ID)
+			assert.Equal(t, 0, shutdownErr.Jobs[0].Executor)
+		}
+	})
+
 	t.Run("Race", func(t *testing.T) {
 		var count int64
 
@@ -191,6 +344,345 @@ func TestWorker_Shutdown(t *testing.T) {
 	})
 }
 
+func TestWorker_Wait(t *testing.T) {
+	w := NewWorker(
+		func(state State) error {
+			return nil
+		},
+		WithSuccessTimeout(1*time.Second),
+	)
+
+	assert.NoError(t, w.Wait(context.Background()))
+	assert.NoError(t, w.Run())
+
+	select {
+	case <-w.Done():
+		t.Fatal("the worker is expected to be running")
+	default:
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	assert.Equal(t, context.DeadlineExceeded, w.Wait(ctx))
+	assert.NoError(t, w.Shutdown(context.Background()))
+	assert.NoError(t, w.Wait(context.Background()))
+	<-w.Done()
+}
+
+func TestWorker_Status(t *testing.T) {
+	t.Run("Lifecycle", func(t *testing.T) {
+		started := make(chan struct{}, 1)
+		release := make(chan struct{})
+
+		w := NewWorker(
+			func(state State) error {
+				select {
+				case started <- struct{}{}:
+				default:
+				}
+				<-release
+
+				return nil
+			},
+			WithRunDelay(50*time.Millisecond),
+		)
+
+		assert.Equal(t, StatusIdle, w.Status())
+		assert.NoError(t, w.Run())
+		assert.Equal(t, StatusDelayed, w.Status())
+
+		<-started
+		assert.Equal(t, StatusRunning, w.Status())
+
+		go func() {
+			_ = w.Pause(context.Background())
+		}()
+		release <- struct{}{}
+		assert.Eventually(t, func() bool {
+			return w.Status() == StatusPaused
+		}, time.Second, 10*time.Millisecond)
+
+		assert.NoError(t, w.Resume())
+		<-started
+
+		shutdownErr := make(chan error)
+		go func() {
+			shutdownErr <- w.Shutdown(context.Background())
+		}()
+		assert.Eventually(t, func() bool {
+			return w.Status() == StatusStopping
+		}, time.Second, 10*time.Millisecond)
+
+		close(release)
+		assert.NoError(t, <-shutdownErr)
+		assert.Equal(t, StatusStopped, w.Status())
+	})
+
+	t.Run("Stats", func(t *testing.T) {
+		var jobs int64
+		errTest := errors.New("test error")
+		done := make(chan struct{})
+
+		w := NewWorker(func(state State) error {
+			switch atomic.AddInt64(&jobs, 1) {
+			case 1:
+				return nil
+			case 2:
+				return errTest
+			case 3:
+				return ErrIdleJob
+			default:
+				<-done
+				return nil
+			}
+		})
+
+		assert.NoError(t, w.Run())
+		assert.Eventually(t, func() bool {
+			return w.Stats().InFlight == 1
+		}, time.Second, 10*time.Millisecond)
+
+		assert.Equal(t, Stats{InFlight: 1, Started: 4, Succeeded: 1, Failed: 1, Idle: 1}, w.Stats())
+
+		close(done)
+		assert.NoError(t, w.Shutdown(context.Background()))
+
+		stats := w.Stats()
+		assert.Equal(t, 0, stats.InFlight)
+		assert.Equal(t, stats.Started, stats.Succeeded+stats.Failed+stats.Idle)
+	})
+}
+
+func TestWorker_Pause(t *testing.T) {
+	t.Run("NotRunning", func(t *testing.T) {
+		w := NewWorker(func(state State) error {
+			return nil
+		})
+
+		assert.Equal(t, ErrWorkerClosed, w.Pause(context.Background()))
+		assert.Equal(t, ErrWorkerClosed, w.Resume())
+	})
+
+	t.Run("PauseResume", func(t *testing.T) {
+		var jobs int64
+		started := make(chan struct{}, 1)
+		release := make(chan struct{})
+		var paused, resumed int64
+
+		w := NewWorker(
+			func(state State) error {
+				atomic.AddInt64(&jobs, 1)
+				select {
+				case started <- struct{}{}:
+				default:
+				}
+				<-release
+
+				return nil
+			},
+			WithSubscriber(func(s Subscriber) {
+				s.ListenPause(func(err error) {
+					assert.NoError(t, err)
+					atomic.AddInt64(&paused, 1)
+				})
+				s.ListenResume(func(err error) {
+					if err == nil {
+						atomic.AddInt64(&resumed, 1)
+					}
+				})
+			}),
+		)
+
+		assert.NoError(t, w.Run())
+		<-started
+
+		pauseErr := make(chan error)
+		go func() {
+			pauseErr <- w.Pause(context.Background())
+		}()
+
+		select {
+		case <-pauseErr:
+			t.Fatal("pause must wait for the job in progress")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		release <- struct{}{}
+		assert.NoError(t, <-pauseErr)
+
+		select {
+		case <-started:
+			t.Fatal("the paused worker must not start new jobs")
+		case <-time.After(50 * time.Millisecond):
+		}
+		assert.Equal(t, int64(1), atomic.LoadInt64(&jobs))
+
+		assert.NoError(t, w.Resume())
+		assert.Equal(t, ErrNotPaused, w.Resume())
+		<-started
+		close(release)
+
+		assert.NoError(t, w.Shutdown(context.Background()))
+		assert.Equal(t, int64(1), atomic.LoadInt64(&paused))
+		assert.Equal(t, int64(1), atomic.LoadInt64(&resumed))
+	})
+
+	t.Run("ShutdownPaused", func(t *testing.T) {
+		w := NewWorker(
+			func(state State) error {
+				return nil
+			},
+			WithSuccessTimeout(1*time.Second),
+		)
+
+		assert.NoError(t, w.Run())
+		assert.NoError(t, w.Pause(context.Background()))
+		assert.NoError(t, w.Shutdown(context.Background()))
+	})
+}
+
+func TestWorker_SetJobsLimit(t *testing.T) {
+	t.Run("NotRunning", func(t *testing.T) {
+		w := NewWorker(func(state State) error {
+			return nil
+		})
+
+		assert.Equal(t, ErrJobsLimit, w.SetJobsLimit(0))
+		assert.NoError(t, w.SetJobsLimit(5))
+		assert.Equal(t, 5, w.(*worker).config.jobsLimit)
+	})
+
+	t.Run("Resize", func(t *testing.T) {
+		var inflight, maxInflight int64
+		started := make(chan struct{}, 10)
+		release := make(chan struct{})
+
+		w := NewWorker(
+			func(state State) error {
+				n := atomic.AddInt64(&inflight, 1)
+				defer atomic.AddInt64(&inflight, -1)
+
+				for {
+					m := atomic.LoadInt64(&maxInflight)
+					if n <= m || atomic.CompareAndSwapInt64(&maxInflight, m, n) {
+						break
+					}
+				}
+
+				select {
+				case started <- struct{}{}:
+				default:
+				}
+				<-release
+
+				return nil
+			},
+			WithJobsLimit(1),
+		)
+
+		assert.NoError(t, w.Run())
+		<-started
+
+		assert.NoError(t, w.SetJobsLimit(3))
+		<-started
+		<-started
+		assert.Equal(t, int64(3), atomic.LoadInt64(&maxInflight))
+
+		assert.NoError(t, w.SetJobsLimit(1))
+
+		// the jobs in progress are finished, only one executor continues
+		close(release)
+		time.Sleep(50 * time.Millisecond)
+		atomic.StoreInt64(&maxInflight, 0)
+		time.Sleep(50 * time.Millisecond)
+
+		assert.Equal(t, int64(1), atomic.LoadInt64(&maxInflight))
+		assert.NoError(t, w.Shutdown(context.Background()))
+
+		p := w.(*worker).pool
+		assert.Len(t, p.slots, 3)
+		assert.False(t, p.slots[1].alive)
+		assert.False(t, p.slots[2].alive)
+	})
+
+	t.Run("ShrinkThenGrow", func(t *testing.T) {
+		started := make(chan int, 10)
+		release := []chan struct{}{make(chan struct{}, 1), make(chan struct{}, 1)}
+
+		w := NewWorker(
+			func(state State) error {
+				executor := slotFromContext(state.Context()).index
+				started <- executor
+
+				select {
+				case <-release[executor]:
+				case <-state.Context().Done():
+				}
+
+				return nil
+			},
+			WithJobsLimit(2),
+		)
+
+		assert.NoError(t, w.Run())
+		<-started
+		<-started
+
+		// the second executor finishes its job and exits
+		assert.NoError(t, w.SetJobsLimit(1))
+		release[1] <- struct{}{}
+
+		p := w.(*worker).pool
+		assert.Eventually(t, func() bool {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+
+			return !p.slots[1].alive
+		}, time.Second, 10*time.Millisecond)
+		assert.Equal(t, 1, p.inFlight())
+
+		// the executor is started again
+		assert.NoError(t, w.SetJobsLimit(2))
+		assert.Equal(t, 1, <-started)
+		assert.Equal(t, 2, p.inFlight())
+
+		assert.NoError(t, w.Shutdown(context.Background()))
+	})
+}
+
+func TestWorker_ErrorClass(t *testing.T) {
+	errRateLimited := errors.New("rate limited")
+	type jobError struct {
+		class ErrorClass
+		err   error
+	}
+	jobErrors := make(chan jobError, 1)
+
+	w := NewWorker(
+		func(state State) error {
+			err := fmt.Errorf("api: %w", errRateLimited)
+			assert.Equal(t, ErrorClass("rate limited"), ErrorClassFromState(state, err))
+			assert.Equal(t, ClassIdle, ErrorClassFromState(state, ErrIdleJob))
+
+			return err
+		},
+		WithErrorClass(errRateLimited, 1*time.Second),
+		WithSubscriber(func(s Subscriber) {
+			s.ListenJobError(func(class ErrorClass, err error) {
+				jobErrors <- jobError{class: class, err: err}
+			})
+		}),
+	)
+
+	assert.NoError(t, w.Run())
+
+	e := <-jobErrors
+	assert.Equal(t, ErrorClass("rate limited"), e.class)
+	assert.True(t, errors.Is(e.err, errRateLimited))
+	assert.NoError(t, w.Shutdown(context.Background()))
+}
+
 func TestWorker_PanicHandle(t *testing.T) {
 	t.Run("CustomMiddleware", func(t *testing.T) {
 		t.Run("WithJobsLimit_Before", func(t *testing.T) {
@@ -220,6 +712,7 @@ func TestWorker_PanicHandle(t *testing.T) {
 			wg.Add(1)
 			assert.NoError(t, w.Run())
 			wg.Wait()
+			assert.NoError(t, w.Shutdown(context.Background()))
 		})
 
 		t.Run("WithJobsLimit_After", func(t *testing.T) {
@@ -249,6 +742,7 @@ func TestWorker_PanicHandle(t *testing.T) {
 			wg.Add(1)
 			assert.NoError(t, w.Run())
 			wg.Wait()
+			assert.NoError(t, w.Shutdown(context.Background()))
 		})
 
 		t.Run("WithoutJobsLimit", func(t *testing.T) {
@@ -277,10 +771,196 @@ func TestWorker_PanicHandle(t *testing.T) {
 			wg.Add(1)
 			assert.NoError(t, w.Run())
 			wg.Wait()
+			assert.NoError(t, w.Shutdown(context.Background()))
 		})
 	})
 }
 
+func TestWorker_ExitOnPanic(t *testing.T) {
+	w := NewWorker(
+		func(state State) error {
+			panic("test panic")
+		},
+		WithExitOnPanic(),
+	)
+
+	assert.NoError(t, w.Run())
+	assert.NoError(t, w.Wait(context.Background()))
+
+	if assert.Error(t, w.Err()) {
+		assert.Contains(t, w.Err().Error(), "porter: panic test panic")
+	}
+	assert.Equal(t, ErrWorkerClosed, w.Shutdown(context.Background()))
+}
+
+func TestWorker_JobEvents(t *testing.T) {
+	t.Run("StartFinish", func(t *testing.T) {
+		var jobs int64
+		started := make(chan RunningJob, 10)
+		finished := make(chan JobResult, 10)
+
+		w := NewWorker(
+			func(state State) error {
+				if atomic.AddInt64(&jobs, 1) == 1 {
+					return ErrIdleJob
+				}
+				return nil
+			},
+			WithMiddleware(JobIDMiddleware()),
+			WithSuccessTimeout(1*time.Second),
+			WithSubscriber(func(s Subscriber) {
+				s.ListenJobStart(func(job RunningJob) {
+					started <- job
+				})
+				s.ListenJobFinish(func(result JobResult) {
+					finished <- result
+				})
+			}),
+		)
+
+		assert.NoError(t, w.Run())
+
+		job := <-started
+		assert.Equal(t, 0, job.Executor)
+		assert.Empty(t, job.ID)
+
+		result := <-finished
+		assert.Equal(t, ClassIdle, result.Class)
+		assert.Equal(t, ErrIdleJob, result.Err)
+		assert.Equal(t, job.StartedAt, result.StartedAt)
+		assert.NotEmpty(t, result.ID)
+
+		<-started
+		result = <-finished
+		assert.Equal(t, ClassSuccess, result.Class)
+		assert.NoError(t, result.Err)
+		assert.True(t, result.Duration >= 0)
+
+		assert.NoError(t, w.Shutdown(context.Background()))
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		timeouts := make(chan error, 10)
+
+		w := NewWorker(
+			func(state State) error {
+				<-state.Context().Done()
+				return nil
+			},
+			WithMiddleware(JobTTLMiddleware(10*time.Millisecond)),
+			WithSubscriber(func(s Subscriber) {
+				s.ListenJobTimeout(func(_ RunningJob, err error) {
+					timeouts <- err
+				})
+			}),
+		)
+
+		assert.NoError(t, w.Run())
+		assert.Equal(t, context.DeadlineExceeded, <-timeouts)
+		assert.NoError(t, w.Shutdown(context.Background()))
+	})
+
+	t.Run("RecoveredPanic", func(t *testing.T) {
+		panics := make(chan error, 10)
+
+		w := NewWorker(
+			func(state State) error {
+				panic("test panic")
+			},
+			WithMiddleware(RecoverMiddleware()),
+			WithSuccessTimeout(1*time.Second),
+			WithErrorTimeout(1*time.Second),
+			WithSubscriber(func(s Subscriber) {
+				s.ListenJobPanic(func(_ RunningJob, err error) {
+					panics <- err
+				})
+			}),
+		)
+
+		assert.NoError(t, w.Run())
+		assert.Contains(t, (<-panics).Error(), "porter: panic test panic")
+		assert.NoError(t, w.Shutdown(context.Background()))
+		assert.Len(t, panics, 0)
+	})
+
+	t.Run("ExitOnPanic", func(t *testing.T) {
+		panics := make(chan error, 10)
+
+		w := NewWorker(
+			func(state State) error {
+				panic("test panic")
+			},
+			WithExitOnPanic(),
+			WithSubscriber(func(s Subscriber) {
+				s.ListenJobPanic(func(_ RunningJob, err error) {
+					panics <- err
+				})
+			}),
+		)
+
+		assert.NoError(t, w.Run())
+		assert.NoError(t, w.Wait(context.Background()))
+		assert.Equal(t, w.Err(), <-panics)
+	})
+}
+
+// Test_poolExit checks only exit: the gap between the executor exceeding the limit and releasing its slot
+// can't be reached through the API, so the slot is set up as if its executor has just returned
+func Test_poolExit(t *testing.T) {
+	started := make(chan int, 10)
+
+	w := NewWorker(
+		func(state State) error {
+			started <- slotFromContext(state.Context()).index
+			<-state.Context().Done()
+
+			return nil
+		},
+		WithJobsLimit(1),
+	)
+
+	assert.NoError(t, w.Run())
+	<-started
+
+	p := w.(*worker).pool
+
+	t.Run("WithinLimit", func(t *testing.T) {
+		// the limit has grown back to 2 before the executor of the second slot released it
+		p.mu.Lock()
+		sl := &slot{pool: p, index: 1, alive: true}
+		p.slots = append(p.slots, sl)
+		p.limit = 2
+		p.wg.Add(1)
+		p.mu.Unlock()
+
+		p.exit(sl)
+
+		select {
+		case executor := <-started:
+			assert.Equal(t, 1, executor)
+		case <-time.After(time.Second):
+			assert.Fail(t, "the executor is not restarted")
+		}
+	})
+
+	t.Run("ExceedsLimit", func(t *testing.T) {
+		p.mu.Lock()
+		sl := &slot{pool: p, index: 2, alive: true}
+		p.slots = append(p.slots, sl)
+		p.wg.Add(1)
+		p.mu.Unlock()
+
+		p.exit(sl)
+
+		p.mu.Lock()
+		assert.False(t, sl.alive)
+		p.mu.Unlock()
+		assert.Len(t, started, 0)
+	})
+
+	assert.NoError(t, w.Shutdown(context.Background()))
+}
+
 func Test_getTimeout(t *testing.T) {
 	config := workerConfig{
 		errorTimeout:   1,
@@ -289,17 +969,49 @@ func Test_getTimeout(t *testing.T) {
 	}
 
 	t.Run("IdleTimeout", func(t *testing.T) {
-		timeout := getTimeout(config, ErrIdleJob)
+		timeout := getTimeout(config, ErrIdleJob, &failureStreak{})
 		assert.Equal(t, config.idleTimeout, timeout)
 	})
 
 	t.Run("SuccessTimeout", func(t *testing.T) {
-		timeout := getTimeout(config, nil)
+		timeout := getTimeout(config, nil, &failureStreak{})
 		assert.Equal(t, config.successTimeout, timeout)
 	})
 
 	t.Run("ErrorTimeout", func(t *testing.T) {
-		timeout := getTimeout(config, errors.New("test"))
+		timeout := getTimeout(config, errors.New("test"), &failureStreak{})
 		assert.Equal(t, config.errorTimeout, timeout)
 	})
+
+	t.Run("WrappedIdle", func(t *testing.T) {
+		timeout := getTimeout(config, fmt.Errorf("no messages: %w", ErrIdleJob), &failureStreak{})
+		assert.Equal(t, config.idleTimeout, timeout)
+	})
+
+	t.Run("ErrorClass", func(t *testing.T) {
+		errRateLimited := errors.New("rate limited")
+
+		c := config
+		c.errorClasses = []errorClass{{class: "rate limited", target: errRateLimited, timeout: 4}}
+
+		timeout := getTimeout(c, fmt.Errorf("api: %w", errRateLimited), &failureStreak{})
+		assert.Equal(t, time.Duration(4), timeout)
+	})
+
+	t.Run("ErrorBackoff", func(t *testing.T) {
+		c := config
+		c.errorBackoff = ExponentialBackoff(10, 100)
+		streak := &failureStreak{}
+
+		assert.Equal(t, time.Duration(10), getTimeout(c, errors.New("test"), streak))
+		assert.Equal(t, time.Duration(20), getTimeout(c, errors.New("test"), streak))
+		assert.Equal(t, time.Duration(40), getTimeout(c, errors.New("test"), streak))
+		assert.Equal(t, c.successTimeout, getTimeout(c, nil, streak))
+		assert.Equal(t, time.Duration(10), getTimeout(c, errors.New("test"), streak))
+	})
+
+	t.Run("CircuitOpen", func(t *testing.T) {
+		timeout := getTimeout(config, ErrCircuitOpen, &failureStreak{})
+		assert.Equal(t, config.idleTimeout, timeout)
+	})
 }
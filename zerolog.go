@@ -1,6 +1,8 @@
 package porter
 
 import (
+	"errors"
+
 	"github.com/rs/zerolog"
 )
 
@@ -28,6 +30,26 @@ func ZerologSubscriber(logger *zerolog.Logger) func(Subscriber) {
 				logger.Info().Msg("worker stopped")
 			}
 		})
+
+		s.ListenPause(func(err error) {
+			if err != nil {
+				logger.Error().Err(err).Msg("worker pause failed")
+			} else {
+				logger.Info().Msg("worker paused")
+			}
+		})
+
+		s.ListenResume(func(err error) {
+			if err != nil {
+				logger.Error().Err(err).Msg("worker resume failed")
+			} else {
+				logger.Info().Msg("worker resumed")
+			}
+		})
+
+		s.ListenJobPanic(func(job RunningJob, err error) {
+			logger.Error().Err(err).Str("job_id", job.ID).Msg("job panic")
+		})
 	}
 }
 
@@ -36,16 +58,26 @@ func ZerologMiddleware(logger *zerolog.Logger) MiddlewareFunc {
 	return func(next JobFunc) JobFunc {
 		return func(state State) error {
 			err := next(state)
+			if err == nil || errors.Is(err, ErrWorkerClosed) {
+				return err
+			}
 
-			switch err {
-			case ErrWorkerClosed, ErrIdleJob:
+			class := ErrorClassFromState(state, err)
+			if class == ClassIdle {
 				return err
 			}
 
-			if err != nil {
-				logger.Error().Err(err).Str("job_id", JobIDFromState(state)).Msg("job error")
+			event := logger.Error()
+			if class != ClassError {
+				// errors of the registered classes are expected, e.g. rate limiting
+				event = logger.Warn()
 			}
 
+			event.Err(err).
+				Str("job_id", JobIDFromState(state)).
+				Str("error_class", string(class)).
+				Msg("job error")
+
 			return err
 		}
 	}